@@ -0,0 +1,343 @@
+package aeaconf2
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	fileModeType = reflect.TypeOf(os.FileMode(0))
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// Argument is a parameter of a registered function, bound to a struct field.
+// Its `acf` struct tag holds a comma-separated list of options:
+//
+//	Owner string `acf:"owner,default=root"`
+//
+// where a leading option without '=' renames the argument, `optional` lets
+// it be left out (as its zero value) and `default=` (which must come last,
+// and may itself contain commas) gives the value used when it is left out.
+type Argument struct {
+	// name used in signatures and `name=value` arguments
+	Name  string
+	Field reflect.StructField
+	// index of the field in the function struct
+	Index int
+
+	Optional   bool
+	HasDefault bool
+	Default    string
+}
+
+// FunctionArguments lists the arguments of a function type in the order they
+// are written, skipping BaseCondition
+func FunctionArguments(ty reflect.Type) []Argument {
+	var args []Argument
+	for i := 1; i < ty.NumField(); i++ {
+		field := ty.Field(i)
+		arg := Argument{Name: field.Name, Field: field, Index: i}
+
+		tag := field.Tag.Get("acf")
+		for j := 0; tag != ""; j++ {
+			if value, ok := strings.CutPrefix(tag, "default="); ok {
+				arg.Optional = true
+				arg.HasDefault = true
+				arg.Default = value
+				break
+			}
+
+			option, rest, _ := strings.Cut(tag, ",")
+			if option == "optional" {
+				arg.Optional = true
+			} else if j == 0 && option != "" {
+				arg.Name = option
+			}
+			tag = rest
+		}
+		args = append(args, arg)
+	}
+	return args
+}
+
+// requiredArguments counts the arguments that can't be left out
+func requiredArguments(args []Argument) int {
+	required := 0
+	for _, arg := range args {
+		if !arg.Optional {
+			required++
+		}
+	}
+	return required
+}
+
+// describeArgumentCount describes how many arguments a function takes,
+// e.g. "1 argument" or "1 to 3 arguments"
+func describeArgumentCount(args []Argument) string {
+	required := requiredArguments(args)
+	if required == len(args) {
+		return fmt.Sprintf("%d argument%s", required, plural(required))
+	}
+	return fmt.Sprintf("%d to %d arguments", required, len(args))
+}
+
+// parseDefault converts the `default=` value of an argument to its type.
+// Defaults of lists are comma-separated.
+func parseDefault(arg Argument) (reflect.Value, error) {
+	return parseDefaultValue(arg.Field.Type, arg.Default)
+}
+
+func parseDefaultValue(ty reflect.Type, text string) (reflect.Value, error) {
+	if ty.Kind() != reflect.Slice {
+		return parseLiteral(ty, text)
+	}
+
+	value := reflect.New(ty).Elem()
+	if text == "" {
+		return value, nil
+	}
+	for _, part := range strings.Split(text, ",") {
+		elem, err := parseLiteral(ty.Elem(), part)
+		if err != nil {
+			return value, err
+		}
+		value = reflect.Append(value, elem)
+	}
+	return value, nil
+}
+
+// parseLiteral converts the text of a literal to a value of type ty, by
+// the same rules for defaults as for arguments written in checks
+func parseLiteral(ty reflect.Type, text string) (reflect.Value, error) {
+	value := reflect.New(ty).Elem()
+	switch ArgumentTypeName(ty) {
+	case "string":
+		value.SetString(text)
+	case "mode":
+		mode, err := strconv.ParseUint(text, 8, 32)
+		if err != nil || mode > 07777 {
+			return value, fmt.Errorf("invalid file mode '%s'", text)
+		}
+		value.Set(reflect.ValueOf(fileModeFromUnix(mode)))
+	case "duration":
+		duration, err := time.ParseDuration(text)
+		if err != nil {
+			return value, err
+		}
+		value.SetInt(int64(duration))
+	case "int":
+		num, err := strconv.ParseInt(text, 10, 64)
+		if err != nil || value.OverflowInt(num) {
+			return value, fmt.Errorf("invalid integer '%s'", text)
+		}
+		value.SetInt(num)
+	case "bool":
+		if text != "true" && text != "false" {
+			return value, fmt.Errorf("invalid boolean '%s': expected true or false", text)
+		}
+		value.SetBool(text == "true")
+	case "float":
+		f, err := strconv.ParseFloat(text, 64)
+		if errors.Is(err, strconv.ErrRange) || (err == nil && value.OverflowFloat(f)) {
+			return value, fmt.Errorf("'%s' doesn't fit in %s", text, ty)
+		} else if err != nil {
+			return value, fmt.Errorf("invalid number '%s'", text)
+		}
+		value.SetFloat(f)
+	default:
+		return value, fmt.Errorf("unsupported type '%s'", ty)
+	}
+	return value, nil
+}
+
+// matches reports whether a `name=value` argument refers to arg
+func (arg Argument) matches(name string) bool {
+	return name == arg.Name || name == arg.Field.Name
+}
+
+// IsList reports whether arg binds a list of values
+func (arg Argument) IsList() bool {
+	return arg.Field.Type.Kind() == reflect.Slice
+}
+
+// ArgumentTypeName is the short name of a function argument's type, as
+// shown in signatures. It returns "" for types arguments can't bind to.
+func ArgumentTypeName(ty reflect.Type) string {
+	switch ty {
+	case fileModeType:
+		return "mode"
+	case durationType:
+		return "duration"
+	}
+
+	switch ty.Kind() {
+	case reflect.Slice:
+		// lists of lists aren't supported
+		if elem := ArgumentTypeName(ty.Elem()); elem != "" && ty.Elem().Kind() != reflect.Slice {
+			return "[]" + elem
+		}
+		return ""
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "int"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	default:
+		return ""
+	}
+}
+
+// describe an argument type the way an image author would expect it written
+func describeArgumentType(ty reflect.Type) string {
+	switch ArgumentTypeName(ty) {
+	case "mode":
+		return "an octal file mode (e.g. 0644)"
+	case "duration":
+		return "a duration (e.g. 30s or 1h30m)"
+	case "int":
+		return "an integer"
+	case "bool":
+		return "true or false"
+	case "float":
+		return "a number"
+	default:
+		return "a string"
+	}
+}
+
+// isArgumentStart reports whether a token can begin a function argument
+func isArgumentStart(token *Token) bool {
+	switch token.Type {
+	case TokenString, TokenNumber, TokenFloat, TokenDuration, TokenIdent, TokenLBrace:
+		return true
+	default:
+		return false
+	}
+}
+
+// BindArgument binds the literal `token` to argument `arg` (whose field value
+// is `dest`) of function `funcName`. Lists are bound from a list literal
+// starting at `token`, or have `token` appended to them.
+func (p *Parser) BindArgument(funcName string, arg Argument, token *Token, dest reflect.Value, notes []Note) {
+	if !arg.IsList() {
+		p.bindValue(funcName, arg, token, dest, notes)
+		return
+	}
+
+	if token.Type == TokenLBrace {
+		if dest.Len() != 0 {
+			p.ErrorWithNotes(CodeSyntax, token.Span, notes,
+				"argument '%s' of '%s' is given both as repeated arguments and as a list", arg.Name, funcName)
+		}
+		p.parseListArgument(funcName, arg, token, dest, notes)
+		return
+	}
+	elem := reflect.New(dest.Type().Elem()).Elem()
+	p.bindValue(funcName, arg, token, elem, notes)
+	dest.Set(reflect.Append(dest, elem))
+}
+
+// parseListArgument binds the elements of a `{ a, b, ... }` list literal,
+// whose opening brace `open` has been consumed. Lists may span lines.
+func (p *Parser) parseListArgument(funcName string, arg Argument, open *Token, dest reflect.Value, notes []Note) {
+	list := reflect.MakeSlice(dest.Type(), 0, 0)
+	for {
+		p.skipLineBreaks()
+		if p.Peek().Type == TokenRBrace {
+			break
+		}
+
+		elem := reflect.New(dest.Type().Elem()).Elem()
+		p.bindValue(funcName, arg, p.Consume(), elem, notes)
+		list = reflect.Append(list, elem)
+
+		p.skipLineBreaks()
+		if p.Peek().Type != TokenComma {
+			break
+		}
+		p.Consume()
+	}
+
+	if p.Peek().Type != TokenRBrace {
+		p.ErrorWithNotes(CodeSyntax, p.currentSpan(), []Note{{Message: "list opened here", Span: open.Span}},
+			"expected ',' or '}' in list for argument '%s' of '%s', found %s", arg.Name, funcName, p.Peek().Describe())
+	}
+	p.Consume()
+	dest.Set(list)
+}
+
+// skipLineBreaks skips the newlines and indentation inside a list literal
+func (p *Parser) skipLineBreaks() {
+	for p.Peek().Type == NewTokenline || p.Peek().Type == TokenIndent {
+		p.Consume()
+	}
+}
+
+// bindValue binds a single literal to `dest`, a value or list element of
+// argument `arg`
+func (p *Parser) bindValue(funcName string, arg Argument, token *Token, dest reflect.Value, notes []Note) {
+	typeError := func() {
+		p.ErrorWithNotes(CodeArgumentType, token.Span, notes, "expected %s for argument '%s' of '%s', found %s",
+			describeArgumentType(dest.Type()), arg.Name, funcName, token.Describe())
+	}
+
+	var literal bool
+	switch ArgumentTypeName(dest.Type()) {
+	case "string":
+		if token.Type != TokenString {
+			typeError()
+		}
+		dest.SetString(p.Interpolate(token))
+		return
+	case "mode", "int":
+		literal = token.Type == TokenNumber
+	case "duration":
+		literal = token.Type == TokenDuration
+	case "bool":
+		literal = isKeyword(token, "true") || isKeyword(token, "false")
+	case "float":
+		literal = token.Type == TokenNumber || token.Type == TokenFloat
+	default:
+		// registries checked with CheckFunctionRegistry never get here
+		p.ErrorWithNotes(CodeArgumentType, token.Span, notes, "argument '%s' of '%s' has unsupported type '%s'",
+			arg.Name, funcName, dest.Type())
+	}
+	if !literal {
+		typeError()
+	}
+
+	value, err := parseLiteral(dest.Type(), string(token.Lexeme))
+	if err != nil {
+		if ArgumentTypeName(dest.Type()) == "mode" {
+			// e.g. 0648, which isn't octal
+			typeError()
+		}
+		p.ErrorWithNotes(CodeArgumentType, token.Span, notes, "argument '%s' of '%s' is out of range: %s",
+			arg.Name, funcName, token.Lexeme)
+	}
+	dest.Set(value)
+}
+
+// fileModeFromUnix converts unix permission bits (e.g. 04755) to an
+// os.FileMode, whose setuid, setgid and sticky bits are laid out differently
+func fileModeFromUnix(mode uint64) os.FileMode {
+	fileMode := os.FileMode(mode) & os.ModePerm
+	if mode&04000 != 0 {
+		fileMode |= os.ModeSetuid
+	}
+	if mode&02000 != 0 {
+		fileMode |= os.ModeSetgid
+	}
+	if mode&01000 != 0 {
+		fileMode |= os.ModeSticky
+	}
+	return fileMode
+}
@@ -1,23 +1,49 @@
 package aeaconf2
 
 import (
-	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
 	"reflect"
 )
 
 type AeaconfBuilder struct {
+	// name of the file checks are read from; used in source positions
+	FileName     string
 	ChecksRaw    []byte
 	FuncRegistry map[string]reflect.Type
 	MaxPoints    int
 	LineOffset   int
+	// lint codes that will not be reported; see LintCodes
+	DisabledLints map[string]bool
+	// file system `include`d files are read from, relative to FileName;
+	// nil for the OS's
+	FS fs.FS
+	// values `@if` directives test, e.g. the config's [round] section
+	Header map[string]string
+
+	// lint warnings from the last call to Build
+	Warnings []*Diagnostic
 }
 
 func NewAeaconfBuilder() *AeaconfBuilder {
-	return &AeaconfBuilder{}
+	return &AeaconfBuilder{DisabledLints: make(map[string]bool)}
 }
 
 func DefaultAeaconfBuilder(checksRaw []byte, funcRegistry map[string]reflect.Type) *AeaconfBuilder {
-	return &AeaconfBuilder{ChecksRaw: checksRaw, FuncRegistry: funcRegistry, MaxPoints: 100, LineOffset: 0}
+	return &AeaconfBuilder{
+		ChecksRaw:     checksRaw,
+		FuncRegistry:  funcRegistry,
+		MaxPoints:     100,
+		LineOffset:    0,
+		DisabledLints: make(map[string]bool),
+	}
+}
+
+func (a *AeaconfBuilder) SetFileName(fileName string) *AeaconfBuilder {
+	a.FileName = fileName
+	return a
 }
 
 func (a *AeaconfBuilder) SetChecksRaw(checksRaw []byte) *AeaconfBuilder {
@@ -40,11 +66,78 @@ func (a *AeaconfBuilder) SetLineOffset(lineOffset int) *AeaconfBuilder {
 	return a
 }
 
-func (a *AeaconfBuilder) GetChecks() []*Check {
-	l := NewLexer(bytes.TrimSpace(a.ChecksRaw), a.LineOffset)
+func (a *AeaconfBuilder) SetFS(fsys fs.FS) *AeaconfBuilder {
+	a.FS = fsys
+	return a
+}
+
+func (a *AeaconfBuilder) SetHeader(header map[string]string) *AeaconfBuilder {
+	a.Header = header
+	return a
+}
+
+func (a *AeaconfBuilder) DisableLints(codes ...string) *AeaconfBuilder {
+	if a.DisabledLints == nil {
+		a.DisabledLints = make(map[string]bool)
+	}
+	for _, code := range codes {
+		a.DisabledLints[code] = true
+	}
+	return a
+}
+
+func (a *AeaconfBuilder) EnableLints(codes ...string) *AeaconfBuilder {
+	for _, code := range codes {
+		delete(a.DisabledLints, code)
+	}
+	return a
+}
+
+// Build lexes, parses, lints and distributes points for the configured
+// checks. Failures are returned as ParseErrors, holding every *ParseError
+// found; lint warnings are left in a.Warnings.
+func (a *AeaconfBuilder) Build() ([]*Check, error) {
+	a.Warnings = nil
+	l := NewFileLexer(NewSourceFile(a.FileName, trimSource(a.ChecksRaw), a.LineOffset))
 	p := NewParser(l, a.FuncRegistry)
-	checks := p.Checks()
-	DistributeMaxPoints(checks, a.MaxPoints)
+	p.FS = a.FS
+	p.Header = a.Header
+	checks, err := p.Parse()
+	if err != nil {
+		return nil, err
+	}
+	a.Warnings = p.Lint(checks, a.DisabledLints)
 
+	if err := DistributeMaxPoints(checks, a.MaxPoints); err != nil {
+		var pe *ParseError
+		if !errors.As(err, &pe) {
+			return nil, err
+		}
+		pe.files = p.sources.files
+		return nil, ParseErrors{pe}
+	}
+	return checks, nil
+}
+
+// GetChecks is like Build, but prints any warnings, and prints the errors
+// and exits the process on failure
+func (a *AeaconfBuilder) GetChecks() []*Check {
+	checks, err := a.Build()
+	for _, warning := range a.Warnings {
+		fmt.Fprintln(os.Stderr, warning.Render())
+	}
+	if err != nil {
+		var errs ParseErrors
+		var pe *ParseError
+		switch {
+		case errors.As(err, &errs):
+			errs.Fatal()
+		case errors.As(err, &pe):
+			pe.Fatal()
+		default:
+			fmt.Fprintf(os.Stderr, "could not build checks: %s\n", err)
+			os.Exit(1)
+		}
+	}
 	return checks
 }
@@ -1,20 +1,122 @@
 package aeaconf2
 
-import "github.com/fatih/color"
+import (
+	"context"
+
+	"github.com/fatih/color"
+)
 
 type Check struct {
 	Message string
-	Points  int
+	// message was left unspecified and generated from the condition
+	MessageEmpty bool
+	Points       int
 	// points were left unspecified
 	PointsEmpty bool
 
 	Condition
+	// the check only applies if Precondition holds (`when <condition>`);
+	// nil if it always applies
+	Precondition Condition
 	// separate root hint from condition tree
 	Hint string
+	// source of the entire check, header through last condition
+	Span Span
+}
+
+// CheckStatus is the outcome of scoring a check
+type CheckStatus int
+
+const (
+	CheckPassed CheckStatus = iota
+	CheckFailed
+	// the check's precondition doesn't hold (or its condition was
+	// skipped), so it neither awards nor deducts points
+	CheckNotApplicable
+	// the check couldn't be scored; see Check.StatusContext
+	CheckError
+)
+
+func (s CheckStatus) String() string {
+	switch s {
+	case CheckPassed:
+		return "passed"
+	case CheckFailed:
+		return "failed"
+	case CheckNotApplicable:
+		return "not applicable"
+	default:
+		return "error"
+	}
+}
+
+// Score reports whether the check passed: false if its precondition
+// doesn't hold, so that scorers using Score alone neither award nor deduct
+// points for checks that don't apply. See Status to tell the two apart.
+func (c *Check) Score() bool {
+	if c.Precondition != nil && !c.Precondition.Score() {
+		return false
+	}
+	return c.Condition.Score()
+}
+
+// Status scores the check, first testing its precondition if it has one
+func (c *Check) Status() CheckStatus {
+	status, _ := c.StatusContext(context.Background())
+	return status
+}
+
+// StatusContext is like Status, but evaluates conditions that implement
+// Evaluator (see Evaluate), returning the error of a CheckError
+func (c *Check) StatusContext(ctx context.Context) (CheckStatus, error) {
+	if c.Precondition != nil {
+		switch result := Evaluate(ctx, c.Precondition); result.Status {
+		case ResultError:
+			return CheckError, result.Err
+		case ResultFail, ResultSkip:
+			return CheckNotApplicable, nil
+		}
+	}
+
+	switch result := Evaluate(ctx, c.Condition); result.Status {
+	case ResultPass:
+		return CheckPassed, nil
+	case ResultFail:
+		return CheckFailed, nil
+	case ResultSkip:
+		return CheckNotApplicable, nil
+	default:
+		return CheckError, result.Err
+	}
+}
+
+// PointsFor returns the points the check awards with the given status:
+// its Points (negative for penalties) if it passed, and none otherwise
+func (c *Check) PointsFor(status CheckStatus) int {
+	if status == CheckPassed {
+		return c.Points
+	}
+	return 0
+}
+
+// MaxPointsFor returns the most points the check could have awarded given
+// its status: none if it wasn't applicable (or is a penalty), and its Points
+// otherwise. Points distributed with `_` are shared among every check,
+// including those that turn out not to apply, so a round's maximum is only
+// reachable on systems where all apply; summing MaxPointsFor over the checks
+// gives the maximum for this system, e.g. to normalize a score against.
+func (c *Check) MaxPointsFor(status CheckStatus) int {
+	if status == CheckNotApplicable || c.Points < 0 {
+		return 0
+	}
+	return c.Points
 }
 
 func (c *Check) Debug() string {
 	cl := color.New(color.Bold)
 	ret := cl.Sprintf("%s (%d Points)%s\n", c.Message, c.Points, formatHint(c.Hint))
+	if c.Precondition != nil {
+		ret += "WHEN " + DebugCondition(c.Precondition) + "\n"
+	}
 	return ret + DebugCondition(c.Condition)
 }
@@ -0,0 +1,107 @@
+package aeaconf2_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/safinsingh/aeaconf2"
+	"github.com/safinsingh/aeaconf2/compat"
+)
+
+func TestPreconditions(t *testing.T) {
+	// every test function passes, so its Not variant fails
+	source := `"Samba guests are disabled": 3 ["Look in smb.conf"] when ServiceUp "smbd"
+	FileContains "/etc/samba/smb.conf" "map to guest = never"
+"FTP is anonymous-free": 2 when ServiceUpNot "vsftpd"; PathExists "/etc/vsftpd.conf"
+"Telnet is disabled": 4 when PathExists "/usr/sbin/telnetd" || PathExists "/usr/bin/telnetd"; ServiceUpNot "telnetd"
+"Root is locked": -5 when PathExists "/root"; PathExists "/root/.ssh"
+_: 1 when PathExists "/"; ServiceUp "sshd"`
+
+	checks, err := buildChecks(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		status    aeaconf2.CheckStatus
+		points    int
+		maxPoints int
+	}{
+		{aeaconf2.CheckPassed, 3, 3},
+		{aeaconf2.CheckNotApplicable, 0, 0},
+		{aeaconf2.CheckFailed, 0, 4},
+		{aeaconf2.CheckPassed, -5, 0},
+		{aeaconf2.CheckPassed, 1, 1},
+	}
+	for i, tt := range tests {
+		check := checks[i]
+		status := check.Status()
+		if status != tt.status {
+			t.Errorf("%s: expected %s, got %s", check.Message, tt.status, status)
+		}
+		if points := check.PointsFor(status); points != tt.points {
+			t.Errorf("%s: expected %d points, got %d", check.Message, tt.points, points)
+		}
+		if maxPoints := check.MaxPointsFor(status); maxPoints != tt.maxPoints {
+			t.Errorf("%s: expected at most %d points, got %d", check.Message, tt.maxPoints, maxPoints)
+		}
+		if score := check.Score(); score != (status == aeaconf2.CheckPassed) {
+			t.Errorf("%s: expected score %v for %s", check.Message, !score, status)
+		}
+	}
+
+	if hint := checks[0].Hint; hint != "Look in smb.conf" {
+		t.Errorf("unexpected hint: %q", hint)
+	}
+	if _, ok := checks[2].Precondition.(*aeaconf2.OrExpr); !ok {
+		t.Errorf("expected the whole expression to be the precondition, got %T", checks[2].Precondition)
+	}
+	if msg := checks[4].Message; msg != "Service 'sshd' is running" {
+		t.Errorf("expected the generated message to leave out the precondition, got %q", msg)
+	}
+	if checks[4].Precondition == nil || checks[3].Precondition == nil {
+		t.Error("expected preconditions to be set")
+	}
+}
+
+func TestPreconditionErrors(t *testing.T) {
+	_, err := buildChecks(`"a": 3 when; ServiceUp "sshd"`)
+	var pe *aeaconf2.ParseError
+	if !errors.As(err, &pe) || pe.Column != 12 {
+		t.Errorf("expected an error at the missing precondition, got %v", err)
+	}
+
+	// the same condition under another precondition isn't a duplicate
+	ab := aeaconf2.DefaultAeaconfBuilder([]byte("\"a\": 3 when ServiceUp \"smbd\"; PathExists \"/\"\n\"b\": 3; PathExists \"/\""), getFunctionRegistry())
+	if codes := lintCodes(t, ab); len(codes) != 0 {
+		t.Errorf("expected no warnings, got %v", codes)
+	}
+}
+
+func TestModifyCheckStrings(t *testing.T) {
+	checks, err := buildChecks(`"a": 3 when ServiceUp "smbd"; PathExists "/etc/samba"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compat.ModifyCheckStrings(checks[0], strings.ToUpper)
+	if name := checks[0].Precondition.(*ServiceUp).Service; name != "SMBD" {
+		t.Errorf("expected the precondition to be modified, got %q", name)
+	}
+	if path := checks[0].Condition.(*PathExists).Path; path != "/ETC/SAMBA" {
+		t.Errorf("expected the condition to be modified, got %q", path)
+	}
+}
+
+func TestModifyListStrings(t *testing.T) {
+	checks, err := buildChecks(`"a": 3; UsersAbsent "games" "news"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compat.ModifyConditionStrings(checks[0].Condition, strings.ToUpper)
+	if users := checks[0].Condition.(*UsersAbsent).Users; len(users) != 2 || users[0] != "GAMES" || users[1] != "NEWS" {
+		t.Errorf("expected the list to be modified, got %q", users)
+	}
+}
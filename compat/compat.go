@@ -9,6 +9,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/safinsingh/aeaconf2"
+	"gopkg.in/ini.v1"
 )
 
 func SeparateConfig(data []byte) ([]byte, []byte, error) {
@@ -30,6 +31,25 @@ func SeparateConfigFrom(fileName string) ([]byte, []byte, error) {
 	return SeparateConfig(data)
 }
 
+// HeaderValues returns the keys of the header's [round] section, for
+// `@if` directives to test (see AeaconfBuilder.SetHeader)
+func HeaderValues(headerRaw []byte) (map[string]string, error) {
+	cfg, err := ini.Load(headerRaw)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse header")
+	}
+	return cfg.Section("round").KeysHash(), nil
+}
+
+// ModifyCheckStrings applies fun to the strings of a check's condition and
+// of its precondition, if it has one
+func ModifyCheckStrings(check *aeaconf2.Check, fun func(string) string) {
+	ModifyConditionStrings(check.Condition, fun)
+	if check.Precondition != nil {
+		ModifyConditionStrings(check.Precondition, fun)
+	}
+}
+
 func ModifyConditionStrings(cond aeaconf2.Condition, fun func(string) string) {
 	val := reflect.ValueOf(cond).Elem()
 
@@ -43,6 +63,15 @@ func ModifyConditionStrings(cond aeaconf2.Condition, fun func(string) string) {
 			if nestedCond, ok := fieldInterface.(aeaconf2.Condition); ok {
 				ModifyConditionStrings(nestedCond, fun)
 			}
+		} else if conds, ok := field.Interface().([]aeaconf2.Condition); ok {
+			for _, nestedCond := range conds {
+				ModifyConditionStrings(nestedCond, fun)
+			}
+		} else if field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String {
+			// list arguments, e.g. `UsersAbsent "games" "news"`
+			for j := 0; j < field.Len(); j++ {
+				field.Index(j).SetString(fun(field.Index(j).String()))
+			}
 		} else if field.Kind() == reflect.Struct {
 			// Handle BaseCondition
 			for j := 0; j < field.NumField(); j++ {
@@ -0,0 +1,129 @@
+package aeaconf2_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/safinsingh/aeaconf2"
+	"github.com/safinsingh/aeaconf2/compat"
+)
+
+func buildWithHeader(source string, header map[string]string) ([]*aeaconf2.Check, error) {
+	return aeaconf2.DefaultAeaconfBuilder([]byte(source), getFunctionRegistry()).SetHeader(header).Build()
+}
+
+func TestConditionals(t *testing.T) {
+	source := `let distro = "Ubuntu"
+
+@if os == "${distro} 20"
+"UFW is enabled": 2; ServiceUp "ufw"
+@if user != "root"
+"Admin has a home": 2
+	PathExists "/home/cpadmin"
+@else
+"Root is locked": 2; PathExistsNot "/root/.ssh"
+@end
+@else
+"Firewalld is enabled": 2; ServiceUp "firewalld"
+@if user == "cpadmin"
+"Firewalld allows SSH": 2; ServiceUp "sshd"
+@end
+@end
+
+"SSH is running": 2; ServiceUp "sshd"`
+
+	tests := []struct {
+		name     string
+		header   map[string]string
+		messages string
+	}{
+		{"taken", map[string]string{"os": "Ubuntu 20", "user": "cpadmin"}, "UFW is enabled, Admin has a home, SSH is running"},
+		{"nested else", map[string]string{"os": "Ubuntu 20", "user": "root"}, "UFW is enabled, Root is locked, SSH is running"},
+		{"else", map[string]string{"os": "Fedora 38", "user": "cpadmin"}, "Firewalld is enabled, Firewalld allows SSH, SSH is running"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks, err := buildWithHeader(source, tt.header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var messages []string
+			for _, check := range checks {
+				messages = append(messages, check.Message)
+			}
+			if got := strings.Join(messages, ", "); got != tt.messages {
+				t.Errorf("expected %s, got %s", tt.messages, got)
+			}
+		})
+	}
+}
+
+func TestConditionalsFromHeader(t *testing.T) {
+	config := `[round]
+title = Linux Round
+os = Ubuntu 20
+---
+@if os == "Ubuntu 20"
+"UFW is enabled": 2; ServiceUp "ufw"
+@else
+"Firewalld is enabled": 2; ServiceUp "firewalld"
+@end`
+
+	headerRaw, checksRaw, err := compat.SeparateConfig([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := compat.HeaderValues(headerRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checks, err := buildWithHeader(string(checksRaw), header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 1 || checks[0].Message != "UFW is enabled" {
+		t.Errorf("expected only the section for the header's os, got %d checks", len(checks))
+	}
+}
+
+func TestConditionalErrors(t *testing.T) {
+	header := map[string]string{"os": "Ubuntu 20"}
+	tests := []struct {
+		name    string
+		source  string
+		code    string
+		message string
+		line    int
+	}{
+		{"unclosed", "@if os == \"Ubuntu 20\"\n\"a\": 3; ServiceUp \"sshd\"", aeaconf2.CodeSyntax, "'@if' is never closed: expected '@end'", 1},
+		{"end without if", "\"a\": 3; ServiceUp \"sshd\"\n@end", aeaconf2.CodeSyntax, "'@end' without a matching '@if'", 2},
+		{"else without if", "@else", aeaconf2.CodeSyntax, "'@else' without a matching '@if'", 1},
+		{"second else", "@if os == \"x\"\n@else\n@else\n@end", aeaconf2.CodeSyntax, "'@if' has more than one '@else'", 3},
+		{"unknown key", "@if oss == \"x\"\n@end", aeaconf2.CodeUndefinedName, "unknown header value 'oss'", 1},
+		{"missing operator", "@if os \"x\"\n@end", aeaconf2.CodeSyntax, "expected '==' or '!=' following '@if os', found string `\"x\"`", 1},
+		{"unknown directive", "@ifdef os\n", aeaconf2.CodeSyntax, "unknown directive '@ifdef': expected '@if', '@else' or '@end'", 1},
+		{"trailing tokens", "@if os == \"x\" \"y\"\n@end", aeaconf2.CodeSyntax, "expected end of line after '@if', found string `\"y\"`", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := buildWithHeader(tt.source, header)
+			var errs aeaconf2.ParseErrors
+			if !errors.As(err, &errs) || len(errs) != 1 {
+				t.Fatalf("expected 1 error, got %v", err)
+			}
+			if pe := errs[0]; pe.Code != tt.code || pe.Message != tt.message || pe.Line != tt.line {
+				t.Errorf("expected %s %q on line %d, got %s %q on line %d", tt.code, tt.message, tt.line, pe.Code, pe.Message, pe.Line)
+			}
+		})
+	}
+
+	_, err := buildWithHeader("@if os == \"x\"\n@end", nil)
+	var pe *aeaconf2.ParseError
+	if !errors.As(err, &pe) || len(pe.Notes) != 1 || !strings.Contains(pe.Notes[0].Message, "no header values") {
+		t.Errorf("expected a note about the missing header, got %v", err)
+	}
+}
@@ -1,8 +1,10 @@
 package aeaconf2
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"strings"
 )
 
 type Condition interface {
@@ -13,6 +15,8 @@ type Condition interface {
 
 type BaseCondition struct {
 	Hint string
+	// source of the condition, set by the parser
+	Span Span
 }
 
 type AndExpr struct {
@@ -25,6 +29,18 @@ func (a *AndExpr) Score() bool {
 	return a.Lhs.Score() && a.Rhs.Score()
 }
 
+func (a *AndExpr) Evaluate(ctx context.Context) Result {
+	lhs := Evaluate(ctx, a.Lhs)
+	if lhs.Status == ResultFail {
+		return lhs
+	}
+	rhs := Evaluate(ctx, a.Rhs)
+	if rhs.Status == ResultFail || lhs.Status == ResultPass {
+		return rhs
+	}
+	return unknown(lhs, rhs)
+}
+
 func (a *AndExpr) DefaultString() string {
 	return fmt.Sprintf("(%s AND %s)", a.Lhs.DefaultString(), a.Rhs.DefaultString())
 }
@@ -39,13 +55,190 @@ func (o *OrExpr) Score() bool {
 	return o.Lhs.Score() || o.Rhs.Score()
 }
 
+func (o *OrExpr) Evaluate(ctx context.Context) Result {
+	lhs := Evaluate(ctx, o.Lhs)
+	if lhs.Status == ResultPass {
+		return lhs
+	}
+	rhs := Evaluate(ctx, o.Rhs)
+	if rhs.Status == ResultPass || lhs.Status == ResultFail {
+		return rhs
+	}
+	return unknown(lhs, rhs)
+}
+
 func (o *OrExpr) DefaultString() string {
 	return fmt.Sprintf("(%s OR %s)", o.Lhs.DefaultString(), o.Rhs.DefaultString())
 }
 
+// XorExpr holds when exactly one of its operands holds
+type XorExpr struct {
+	BaseCondition
+	Lhs Condition
+	Rhs Condition
+}
+
+func (x *XorExpr) Score() bool {
+	return x.Lhs.Score() != x.Rhs.Score()
+}
+
+func (x *XorExpr) Evaluate(ctx context.Context) Result {
+	lhs, rhs := Evaluate(ctx, x.Lhs), Evaluate(ctx, x.Rhs)
+	if lhs.Known() && rhs.Known() {
+		return BoolResult(lhs.Status != rhs.Status)
+	}
+	return unknown(lhs, rhs)
+}
+
+func (x *XorExpr) DefaultString() string {
+	return fmt.Sprintf("(%s XOR %s)", x.Lhs.DefaultString(), x.Rhs.DefaultString())
+}
+
+// ImpliesExpr holds unless Lhs holds and Rhs doesn't, i.e. "if Lhs then Rhs"
+type ImpliesExpr struct {
+	BaseCondition
+	Lhs Condition
+	Rhs Condition
+}
+
+func (i *ImpliesExpr) Score() bool {
+	return !i.Lhs.Score() || i.Rhs.Score()
+}
+
+func (i *ImpliesExpr) Evaluate(ctx context.Context) Result {
+	lhs := Evaluate(ctx, i.Lhs)
+	if lhs.Status == ResultFail {
+		return BoolResult(true)
+	}
+	rhs := Evaluate(ctx, i.Rhs)
+	if rhs.Status == ResultPass || lhs.Status == ResultPass {
+		return rhs
+	}
+	return unknown(lhs, rhs)
+}
+
+func (i *ImpliesExpr) DefaultString() string {
+	return fmt.Sprintf("(IF %s THEN %s)", i.Lhs.DefaultString(), i.Rhs.DefaultString())
+}
+
+// ThresholdKind is the comparison a ThresholdExpr makes
+type ThresholdKind int
+
+const (
+	AtLeast ThresholdKind = iota
+	Exactly
+	AtMost
+)
+
+// Keyword returns the keyword introducing a threshold of this kind
+func (k ThresholdKind) Keyword() string {
+	switch k {
+	case AtLeast:
+		return "atleast"
+	case Exactly:
+		return "exactly"
+	default:
+		return "atmost"
+	}
+}
+
+func (k ThresholdKind) String() string {
+	switch k {
+	case AtLeast:
+		return "at least"
+	case Exactly:
+		return "exactly"
+	default:
+		return "at most"
+	}
+}
+
+// ThresholdExpr holds when at least, exactly or at most Count of its
+// Conditions hold, e.g. `atleast 2 (A, B, C)`
+type ThresholdExpr struct {
+	BaseCondition
+	Kind       ThresholdKind
+	Count      int
+	Conditions []Condition
+}
+
+func (t *ThresholdExpr) Score() bool {
+	passed := 0
+	for _, cond := range t.Conditions {
+		if cond.Score() {
+			passed++
+		}
+	}
+	return t.holds(passed)
+}
+
+// Evaluate passes if the threshold holds however the unknown results would
+// turn out, and fails if it holds for none of them
+func (t *ThresholdExpr) Evaluate(ctx context.Context) Result {
+	passed, failed := 0, 0
+	var undecided Result
+	for _, cond := range t.Conditions {
+		switch result := Evaluate(ctx, cond); result.Status {
+		case ResultPass:
+			passed++
+		case ResultFail:
+			failed++
+		default:
+			undecided = unknown(undecided, result)
+		}
+	}
+
+	always, never := true, true
+	for n := passed; n <= len(t.Conditions)-failed; n++ {
+		if t.holds(n) {
+			never = false
+		} else {
+			always = false
+		}
+	}
+	if always || never {
+		return BoolResult(always)
+	}
+	return undecided
+}
+
+// holds reports whether the threshold holds when `passed` of its
+// conditions do
+func (t *ThresholdExpr) holds(passed int) bool {
+	switch t.Kind {
+	case AtLeast:
+		return passed >= t.Count
+	case Exactly:
+		return passed == t.Count
+	default:
+		return passed <= t.Count
+	}
+}
+
+func (t *ThresholdExpr) DefaultString() string {
+	var operands []string
+	for _, cond := range t.Conditions {
+		operands = append(operands, cond.DefaultString())
+	}
+	return fmt.Sprintf("(%s %d of: %s)", t.Kind, t.Count, strings.Join(operands, ", "))
+}
+
+// neverFails reports whether the threshold holds however many of its
+// conditions do
+func (t *ThresholdExpr) neverFails() bool {
+	return (t.Kind == AtLeast && t.Count <= 0) || (t.Kind == AtMost && t.Count >= len(t.Conditions))
+}
+
+// neverPasses reports whether the threshold asks for more conditions than
+// it has
+func (t *ThresholdExpr) neverPasses() bool {
+	return t.Kind != AtMost && t.Count > len(t.Conditions)
+}
+
 type NotFunc struct {
 	BaseCondition
-	// Func is always a function call
+	// Func is a function call when negated with the 'Not' suffix, or any
+	// factor (e.g. a parenthesized expression) when negated with '!' or 'not'
 	Func Condition
 }
 
@@ -53,24 +246,56 @@ func (n *NotFunc) Score() bool {
 	return !n.Func.Score()
 }
 
+func (n *NotFunc) Evaluate(ctx context.Context) Result {
+	return not(Evaluate(ctx, n.Func))
+}
+
 func (n *NotFunc) DefaultString() string {
-	return fmt.Sprintf("NOT (%s)", n.Func.DefaultString())
+	switch n.Func.(type) {
+	case *AndExpr, *OrExpr, *XorExpr, *ImpliesExpr, *ThresholdExpr:
+		// already parenthesized
+		return "NOT " + n.Func.DefaultString()
+	default:
+		return fmt.Sprintf("NOT (%s)", n.Func.DefaultString())
+	}
 }
 
 // I still hate go
-func SetConditionHint(cond Condition, newHint string) {
+func baseCondition(cond Condition) *BaseCondition {
 	val := reflect.ValueOf(cond)
 	if val.Kind() == reflect.Ptr && !val.IsNil() {
 		val = val.Elem()
 	}
 
 	baseCond := val.FieldByName("BaseCondition")
-	if baseCond.IsValid() && baseCond.CanSet() {
-		hint := baseCond.FieldByName("Hint")
-		if hint.IsValid() && hint.CanSet() {
-			hint.SetString(newHint)
-			return
+	if baseCond.IsValid() && baseCond.CanAddr() {
+		if base, ok := baseCond.Addr().Interface().(*BaseCondition); ok {
+			return base
 		}
 	}
-	panic("ICE: could not set condition hint")
+	return nil
+}
+
+func SetConditionHint(cond Condition, newHint string) {
+	base := baseCondition(cond)
+	if base == nil {
+		panic("ICE: could not set condition hint")
+	}
+	base.Hint = newHint
+}
+
+func SetConditionSpan(cond Condition, span Span) {
+	base := baseCondition(cond)
+	if base == nil {
+		panic("ICE: could not set condition span")
+	}
+	base.Span = span
+}
+
+func ConditionSpan(cond Condition) Span {
+	base := baseCondition(cond)
+	if base == nil {
+		panic("ICE: could not get condition span")
+	}
+	return base.Span
 }
@@ -0,0 +1,192 @@
+package aeaconf2
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Constant is a top-level `let name = "value"` definition, referenced from
+// strings as `${name}`
+type Constant struct {
+	Name string
+	// value as written, before interpolation
+	Raw string
+	// source of the whole definition
+	Span Span
+
+	value    string
+	resolved bool
+	// whether resolving has been attempted, successfully or not
+	used bool
+}
+
+// ParseDefinition parses a top-level definition if one starts at the next
+// token, reporting whether it did
+func (p *Parser) ParseDefinition() bool {
+	if isKeyword(p.Peek(), "let") {
+		p.ParseLet()
+		return true
+	}
+	if isKeyword(p.Peek(), "def") {
+		p.ParseDef()
+		return true
+	}
+	if isKeyword(p.Peek(), "template") {
+		p.ParseTemplate()
+		return true
+	}
+	return false
+}
+
+// expectDefinitionName consumes the name following the keyword of a
+// definition (`let`, `def` or `template`). Keywords are rejected, since
+// wherever the name was used they would be parsed as keywords instead.
+func (p *Parser) expectDefinitionName(keyword *Token) *Token {
+	nameToken := p.ExpectTokenType(TokenIdent, fmt.Sprintf("expected a name following '%s'", keyword.Lexeme))
+	if slices.Contains(Keywords, string(nameToken.Lexeme)) {
+		p.ErrorAt(nameToken.Span, "'%s' is a keyword, so can't be defined with '%s'", nameToken.Lexeme, keyword.Lexeme)
+	}
+	return nameToken
+}
+
+// ParseLet parses a `let name = "value"` definition
+func (p *Parser) ParseLet() {
+	letToken := p.Consume()
+	nameToken := p.expectDefinitionName(letToken)
+	name := string(nameToken.Lexeme)
+	p.ExpectTokenType(TokenEquals, fmt.Sprintf("expected '=' following 'let %s'", name))
+	value := p.ExpectTokenType(TokenString, fmt.Sprintf("expected a string value for '%s'", name))
+	p.expectEndOfLine(fmt.Sprintf("definition of '%s'", name))
+
+	if existing, ok := p.Constants[name]; ok {
+		p.ErrorWithNotes(CodeDuplicateDefinition, nameToken.Span, []Note{{Message: "first defined here", Span: existing.Span}},
+			"'%s' is already defined", name)
+	}
+	p.Constants[name] = &Constant{
+		Name: name,
+		Raw:  value.Value().(string),
+		Span: letToken.Span.Join(value.Span),
+	}
+}
+
+// expectEndOfLine reports anything but the end of a line following `what`
+func (p *Parser) expectEndOfLine(what string) {
+	if next := p.Peek(); next.Type != NewTokenline && next.Type != TokenEOF {
+		p.Errorf("expected end of line after %s, found %s", what, next.Describe())
+	}
+}
+
+// Interpolate returns the value of a string token with each `${name}`
+// replaced by the value of the constant `name`. `$${` is a literal `${`.
+func (p *Parser) Interpolate(token *Token) string {
+	return p.interpolate(token.Value().(string), token.Span, nil)
+}
+
+// interpolate substitutes the constants referenced in text, found at span.
+// resolving holds the constants whose values are being interpolated, to
+// detect cycles.
+func (p *Parser) interpolate(text string, span Span, resolving []*Constant) string {
+	var sb strings.Builder
+	for {
+		start := strings.Index(text, "${")
+		if start < 0 {
+			sb.WriteString(text)
+			return sb.String()
+		}
+		if start > 0 && text[start-1] == '$' {
+			sb.WriteString(text[:start-1] + "${")
+			text = text[start+2:]
+			continue
+		}
+
+		length := strings.IndexByte(text[start+2:], '}')
+		if length < 0 {
+			p.ErrorAt(span, "unterminated '${' in string: expected '}' to close it (write '$${' for a literal '${')")
+		}
+		name := text[start+2 : start+2+length]
+		sb.WriteString(text[:start])
+		sb.WriteString(p.resolveConstant(name, span, resolving))
+		text = text[start+2+length+1:]
+	}
+}
+
+// resolveConstant returns the value of the template parameter or
+// (interpolated) constant `name`, referenced at span
+func (p *Parser) resolveConstant(name string, span Span, resolving []*Constant) string {
+	// template parameters shadow constants, but not inside constants
+	if len(resolving) == 0 {
+		for i := len(p.scopes) - 1; i >= 0; i-- {
+			if value, ok := p.scopes[i][name]; ok {
+				return value
+			}
+		}
+	}
+
+	constant, ok := p.Constants[name]
+	if !ok {
+		var names []string
+		for defined := range p.Constants {
+			names = append(names, defined)
+		}
+		if len(resolving) == 0 {
+			for _, scope := range p.scopes {
+				for param := range scope {
+					names = append(names, param)
+				}
+			}
+		}
+		sort.Strings(names)
+		names = slices.Compact(names)
+
+		var notes []Note
+		if suggestions := suggest(name, names); len(suggestions) != 0 {
+			notes = append(notes, Note{Message: "did you mean " + formatSuggestions(suggestions) + "?"})
+		}
+		p.ErrorWithNotes(CodeUndefinedName, span, notes, "undefined name '%s' in '${%s}'", name, name)
+	}
+
+	for i, outer := range resolving {
+		if outer == constant {
+			var cycle []string
+			for _, c := range resolving[i:] {
+				cycle = append(cycle, c.Name)
+			}
+			p.ErrorWithNotes(CodeCyclicDefinition, constant.Span, nil, "'%s' is defined in terms of itself: %s -> %s",
+				name, strings.Join(cycle, " -> "), name)
+		}
+	}
+
+	constant.used = true
+	if !constant.resolved {
+		constant.value = p.interpolate(constant.Raw, constant.Span, append(resolving, constant))
+		constant.resolved = true
+	}
+	return constant.value
+}
+
+// checkUnusedConstants resolves every constant no string has used, so that
+// undefined names and cycles in it are still reported
+func (p *Parser) checkUnusedConstants() {
+	var unused []*Constant
+	for _, constant := range p.Constants {
+		if !constant.used {
+			unused = append(unused, constant)
+		}
+	}
+	sort.Slice(unused, func(i, j int) bool {
+		return unused[i].Span.Start.Offset < unused[j].Span.Start.Offset
+	})
+
+	for _, constant := range unused {
+		// an earlier constant may have used (and so reported) this one
+		if constant.used {
+			continue
+		}
+		if pe := catchParseError(func() { p.resolveConstant(constant.Name, constant.Span, nil) }); pe != nil {
+			pe.files = p.sources.files
+			p.Errors = append(p.Errors, pe)
+		}
+	}
+}
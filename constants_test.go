@@ -0,0 +1,85 @@
+package aeaconf2_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/safinsingh/aeaconf2"
+)
+
+func TestConstants(t *testing.T) {
+	source := `let sshd_config = "${ssh_dir}/sshd_config"
+let ssh_dir = "/etc/ssh"
+let user = 'cyberpatriot'
+
+"${user} can't log in as root over SSH": 3 ["Look in ${sshd_config}"]
+	FileContains "${sshd_config}" "PermitRootLogin no"
+	FileContains "/home/${user}/.bashrc" "$${PATH}"`
+
+	checks, err := buildChecks(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := checks[0]
+	if check.Message != "cyberpatriot can't log in as root over SSH" {
+		t.Errorf("unexpected message: %q", check.Message)
+	}
+	if check.Hint != "Look in /etc/ssh/sshd_config" {
+		t.Errorf("unexpected hint: %q", check.Hint)
+	}
+
+	and := check.Condition.(*aeaconf2.AndExpr)
+	if file := and.Lhs.(*FileContains).File; file != "/etc/ssh/sshd_config" {
+		t.Errorf("unexpected argument: %q", file)
+	}
+	if contains := and.Rhs.(*FileContains); contains.File != "/home/cyberpatriot/.bashrc" || contains.Value != "${PATH}" {
+		t.Errorf("unexpected arguments: %+v", contains)
+	}
+}
+
+func TestConstantErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		code    string
+		message string
+	}{
+		{"undefined", `"a": 3; PathExists "${ssh_dir}"`, aeaconf2.CodeUndefinedName, "undefined name 'ssh_dir' in '${ssh_dir}'"},
+		{"defined later", "\"a\": 3; PathExists \"${dir}\"\nlet dir = \"/etc\"", aeaconf2.CodeUndefinedName, "undefined name 'dir' in '${dir}'"},
+		{"cycle", "let a = \"${b}\"\nlet b = \"x${a}\"\n\"a\": 3; PathExists \"${a}\"", aeaconf2.CodeCyclicDefinition, "'a' is defined in terms of itself: a -> b -> a"},
+		{"unused undefined", "let a = \"${b}\"\n\"a\": 3; PathExists \"/\"", aeaconf2.CodeUndefinedName, "undefined name 'b' in '${b}'"},
+		{"unused cycle", "let a = \"${b}\"\nlet b = \"x${a}\"", aeaconf2.CodeCyclicDefinition, "'a' is defined in terms of itself: a -> b -> a"},
+		{"keyword", "let for = \"x\"", aeaconf2.CodeSyntax, "'for' is a keyword, so can't be defined with 'let'"},
+		{"redefined", "let a = \"x\"\nlet a = \"y\"", aeaconf2.CodeDuplicateDefinition, "'a' is already defined"},
+		{"unterminated", `"a": 3; PathExists "${dir"`, aeaconf2.CodeSyntax, "unterminated '${' in string: expected '}' to close it (write '$${' for a literal '${')"},
+		{"not a string", "let a = 3", aeaconf2.CodeSyntax, "expected string, found number `3`: expected a string value for 'a'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := buildChecks(tt.source)
+			var pe *aeaconf2.ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("expected *ParseError, got %v", err)
+			}
+			if pe.Code != tt.code || pe.Message != tt.message {
+				t.Errorf("expected %s %q, got %s %q", tt.code, tt.message, pe.Code, pe.Message)
+			}
+		})
+	}
+
+	// constants used by checks aren't reported again once all are read
+	_, err := buildChecks("let a = \"${b}\"\n\"a\": 3; PathExists \"${a}\"")
+	var errs aeaconf2.ParseErrors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Errorf("expected one error, got %v", err)
+	}
+
+	_, err = buildChecks("let ssh_dir = \"/etc/ssh\"\n\"a\": 3; PathExists \"${ssh_dri}\"")
+	var pe *aeaconf2.ParseError
+	errors.As(err, &pe)
+	if len(pe.Notes) != 1 || pe.Notes[0].Message != "did you mean `ssh_dir`?" {
+		t.Errorf("expected suggestion note, got %+v", pe.Notes)
+	}
+}
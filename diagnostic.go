@@ -0,0 +1,130 @@
+package aeaconf2
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "unknown"
+	}
+}
+
+// Note is extra context attached to a diagnostic. Notes with a span are
+// rendered with their own source excerpt.
+type Note struct {
+	Message string
+	Span    Span
+}
+
+// Diagnostic is a message about the source, rendered in the style of rustc:
+//
+//	error[argument-type]: expected string for argument 'Service' of 'ServiceUp', found name `sshd`
+//	  --> checks.acf:12:12
+//	   |
+//	12 |     ServiceUp sshd
+//	   |               ^^^^
+type Diagnostic struct {
+	Severity Severity
+	Stage    CompilerStage
+	// stable identifier of the kind of diagnostic, e.g. "unknown-function"
+	Code    string
+	Message string
+	Span    Span
+	Notes   []Note
+	// file the spans point into; nil if the diagnostic has no location
+	source *SourceFile
+	// other files spans may point into (e.g. included files), by name
+	files map[string]*SourceFile
+}
+
+// sourceOf returns the file a span points into, if known
+func (d *Diagnostic) sourceOf(span Span) *SourceFile {
+	if d.source != nil && d.source.Name == span.Start.File {
+		return d.source
+	}
+	return d.files[span.Start.File]
+}
+
+func (d *Diagnostic) AddNote(message string, span Span) {
+	d.Notes = append(d.Notes, Note{Message: message, Span: span})
+}
+
+func (d *Diagnostic) Render() string {
+	severity := color.New(color.FgRed, color.Bold)
+	if d.Severity == SeverityWarning {
+		severity = color.New(color.FgYellow, color.Bold)
+	}
+	blue := color.New(color.FgBlue, color.Bold)
+	bold := color.New(color.Bold)
+
+	// every excerpt shares the gutter width of the largest line number
+	width := len(strconv.Itoa(d.Span.End.Line))
+	for _, note := range d.Notes {
+		width = max(width, len(strconv.Itoa(note.Span.End.Line)))
+	}
+	gutter := strings.Repeat(" ", width)
+
+	var sb strings.Builder
+	sb.WriteString(severity.Sprintf("%s[%s]", d.Severity, d.Code) + bold.Sprintf(": %s", d.Message) + "\n")
+	d.renderExcerpt(&sb, d.Span, gutter, severity)
+
+	for _, note := range d.Notes {
+		if note.Span.IsValid() && d.sourceOf(note.Span) != nil {
+			sb.WriteString(blue.Sprint("note") + bold.Sprintf(": %s", note.Message) + "\n")
+			d.renderExcerpt(&sb, note.Span, gutter, blue)
+		} else {
+			sb.WriteString(blue.Sprintf("%s = ", gutter) + fmt.Sprintf("note: %s", note.Message) + "\n")
+		}
+	}
+	return sb.String()
+}
+
+func (d *Diagnostic) renderExcerpt(sb *strings.Builder, span Span, gutter string, underline *color.Color) {
+	source := d.sourceOf(span)
+	if !span.IsValid() || source == nil {
+		return
+	}
+
+	blue := color.New(color.FgBlue, color.Bold)
+	fileName := displayFileName(span.Start.File)
+	sb.WriteString(fmt.Sprintf("%s%s %s:%d:%d\n", gutter, blue.Sprint("-->"), fileName, span.Start.Line, span.Start.Column))
+	sb.WriteString(blue.Sprintf("%s |", gutter) + "\n")
+
+	line := source.LineText(span.Start.Line)
+	sb.WriteString(blue.Sprintf("%*d |", len(gutter), span.Start.Line) + " " + string(line) + "\n")
+
+	// keep tabs so the underline stays aligned with the line above
+	var pad strings.Builder
+	for i := 0; i < span.Start.Column-1 && i < len(line); i++ {
+		if line[i] == '\t' {
+			pad.WriteByte('\t')
+		} else {
+			pad.WriteByte(' ')
+		}
+	}
+
+	// spans running past the first line are underlined to its end
+	end := span.End.Column
+	if span.End.Line != span.Start.Line {
+		end = len(line) + 1
+	}
+	carets := strings.Repeat("^", max(1, end-span.Start.Column))
+	sb.WriteString(blue.Sprintf("%s |", gutter) + " " + pad.String() + underline.Sprint(carets) + "\n")
+}
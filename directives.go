@@ -0,0 +1,102 @@
+package aeaconf2
+
+import (
+	"fmt"
+	"sort"
+)
+
+// conditional is an `@if` section being parsed
+type conditional struct {
+	// the `@if` directive, and `@else` once one is seen
+	start    Span
+	elseSpan Span
+	// whether the section is itself in a kept section
+	outerActive bool
+	// whether the `@if` branch is taken
+	taken bool
+	// whether checks in the current branch are kept
+	active bool
+}
+
+// sectionActive reports whether checks at this point are kept, i.e. they
+// are in no `@if` section or only in taken branches
+func (p *Parser) sectionActive() bool {
+	return len(p.conditionals) == 0 || p.conditionals[len(p.conditionals)-1].active
+}
+
+// ParseDirective parses an `@if key == "value"`, `@if key != "value"`,
+// `@else` or `@end` line. Keys are looked up in p.Header.
+func (p *Parser) ParseDirective() {
+	directive := p.Consume()
+	switch string(directive.Lexeme) {
+	case "@if":
+		// pushed first, so that a broken `@if` still pairs with its `@end`
+		// (and its checks are left out)
+		section := &conditional{start: directive.Span, outerActive: p.sectionActive()}
+		p.conditionals = append(p.conditionals, section)
+
+		keyToken := p.ExpectTokenType(TokenIdent, "expected a header key following '@if'")
+		key := string(keyToken.Lexeme)
+		op := p.Consume()
+		if op.Type != TokenEqEq && op.Type != TokenNotEq {
+			p.ErrorAt(op.Span, "expected '==' or '!=' following '@if %s', found %s", key, op.Describe())
+		}
+		valueToken := p.ExpectTokenType(TokenString, fmt.Sprintf("expected a string to compare '%s' to", key))
+		p.expectEndOfLine("'@if'")
+		section.start = directive.Span.Join(valueToken.Span)
+
+		value, ok := p.Header[key]
+		if !ok {
+			p.ErrorWithNotes(CodeUndefinedName, keyToken.Span, p.headerNotes(key), "unknown header value '%s'", key)
+		}
+		section.taken = (value == p.Interpolate(valueToken)) == (op.Type == TokenEqEq)
+		section.active = section.outerActive && section.taken
+	case "@else":
+		p.expectEndOfLine("'@else'")
+		if len(p.conditionals) == 0 {
+			p.ErrorAt(directive.Span, "'@else' without a matching '@if'")
+		}
+		section := p.conditionals[len(p.conditionals)-1]
+		if section.elseSpan.IsValid() {
+			p.ErrorWithNotes(CodeSyntax, directive.Span, []Note{{Message: "first '@else' here", Span: section.elseSpan}},
+				"'@if' has more than one '@else'")
+		}
+		section.elseSpan = directive.Span
+		section.active = section.outerActive && !section.taken
+	case "@end":
+		p.expectEndOfLine("'@end'")
+		if len(p.conditionals) == 0 {
+			p.ErrorAt(directive.Span, "'@end' without a matching '@if'")
+		}
+		p.conditionals = p.conditionals[:len(p.conditionals)-1]
+	default:
+		p.ErrorAt(directive.Span, "unknown directive '%s': expected '@if', '@else' or '@end'", directive.Lexeme)
+	}
+}
+
+// headerNotes explains an unknown header key
+func (p *Parser) headerNotes(key string) []Note {
+	if len(p.Header) == 0 {
+		return []Note{{Message: "no header values were given to test; see AeaconfBuilder.SetHeader"}}
+	}
+
+	var keys []string
+	for k := range p.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if suggestions := suggest(key, keys); len(suggestions) != 0 {
+		return []Note{{Message: "did you mean " + formatSuggestions(suggestions) + "?"}}
+	}
+	return nil
+}
+
+// checkConditionalsClosed reports an `@if` left open at the end of a file
+func (p *Parser) checkConditionalsClosed() {
+	if len(p.conditionals) == 0 {
+		return
+	}
+	open := p.conditionals[len(p.conditionals)-1]
+	p.conditionals = nil
+	p.ErrorAt(open.start, "'@if' is never closed: expected '@end'")
+}
@@ -0,0 +1,125 @@
+package aeaconf2
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Stable diagnostic codes, used as rule IDs in machine-readable output
+const (
+	CodeSyntax              = "syntax"
+	CodeInvalidToken        = "invalid-token"
+	CodeInvalidEscape       = "invalid-escape"
+	CodeUnknownFunction     = "unknown-function"
+	CodeUnknownArgument     = "unknown-argument"
+	CodeDuplicateArgument   = "duplicate-argument"
+	CodeArgumentType        = "argument-type"
+	CodeArgumentCount       = "argument-count"
+	CodeUndefinedName       = "undefined-name"
+	CodeDuplicateDefinition = "duplicate-definition"
+	CodeCyclicDefinition    = "cyclic-definition"
+	CodeIncludeCycle        = "include-cycle"
+	CodeIncludeNotFound     = "include-not-found"
+	CodeUnclosedParen       = "unclosed-paren"
+	CodeEmptyCheck          = "empty-check"
+	CodeIndentation         = "indentation"
+	CodePointOverflow       = "point-overflow"
+)
+
+// ParseError is a positioned failure raised by any compiler stage.
+// Line and Column are zero for errors with no source location
+// (e.g. point distribution).
+type ParseError struct {
+	Diagnostic
+	Line   int
+	Column int
+}
+
+// NewParseError creates an error at span in source. source may be nil for
+// errors with no location.
+func NewParseError(stage CompilerStage, code string, source *SourceFile, span Span, message string) *ParseError {
+	return &ParseError{
+		Diagnostic: Diagnostic{
+			Severity: SeverityError,
+			Stage:    stage,
+			Code:     code,
+			Message:  message,
+			Span:     span,
+			source:   source,
+		},
+		Line:   span.Start.Line,
+		Column: span.Start.Column,
+	}
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("[%s] %s", e.Stage, e.location())
+}
+
+func (e *ParseError) location() string {
+	if e.Line == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("(line %d, column %d) %s", e.Line, e.Column, e.Message)
+}
+
+// Fatal prints the rendered error and exits
+func (e *ParseError) Fatal() {
+	ParseErrors{e}.Fatal()
+}
+
+// ParseErrors is every error recorded while building checks, in source order
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, pe := range e {
+		messages[i] = pe.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Unwrap allows errors.As to reach each individual *ParseError
+func (e ParseErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, pe := range e {
+		errs[i] = pe
+	}
+	return errs
+}
+
+// Diagnostics returns the underlying diagnostic of every error, e.g. for
+// WriteDiagnosticsJSON
+func (e ParseErrors) Diagnostics() []*Diagnostic {
+	diags := make([]*Diagnostic, len(e))
+	for i, pe := range e {
+		diags[i] = &pe.Diagnostic
+	}
+	return diags
+}
+
+// Fatal prints every rendered error and exits
+func (e ParseErrors) Fatal() {
+	for _, pe := range e {
+		fmt.Fprintln(os.Stderr, pe.Render())
+	}
+
+	Fatal(e[len(e)-1].Stage, fmt.Sprintf("could not build checks due to %d previous error%s", len(e), plural(len(e))))
+}
+
+// catchParseError runs fn, returning the *ParseError it unwound with
+// (via Lexer.Errorf or Parser.Errorf), if any. Any other panic (e.g. an
+// ICE) is propagated untouched.
+func catchParseError(fn func()) (pe *ParseError) {
+	defer func() {
+		if r := recover(); r != nil {
+			var ok bool
+			if pe, ok = r.(*ParseError); !ok {
+				panic(r)
+			}
+		}
+	}()
+	fn()
+	return nil
+}
@@ -2,7 +2,10 @@ package aeaconf2_test
 
 import (
 	"fmt"
+	"os"
 	"reflect"
+	"strings"
+	"time"
 
 	"github.com/safinsingh/aeaconf2"
 )
@@ -13,6 +16,11 @@ func getFunctionRegistry() map[string]reflect.Type {
 	funcRegistry["PathExists"] = reflect.TypeOf(PathExists{})
 	funcRegistry["FileContains"] = reflect.TypeOf(FileContains{})
 	funcRegistry["ServiceUp"] = reflect.TypeOf(ServiceUp{})
+	funcRegistry["FilePermissions"] = reflect.TypeOf(FilePermissions{})
+	funcRegistry["PasswordPolicy"] = reflect.TypeOf(PasswordPolicy{})
+	funcRegistry["FileOwner"] = reflect.TypeOf(FileOwner{})
+	funcRegistry["UsersAbsent"] = reflect.TypeOf(UsersAbsent{})
+	funcRegistry["PortsClosed"] = reflect.TypeOf(PortsClosed{})
 
 	aeaconf2.CheckFunctionRegistry(funcRegistry)
 	return funcRegistry
@@ -58,4 +66,76 @@ func (s *ServiceUp) DefaultString() string {
 	return fmt.Sprintf("Service '%s' is running", s.Service)
 }
 
+type FilePermissions struct {
+	aeaconf2.BaseCondition
+	Path string      `acf:"path"`
+	Mode os.FileMode `acf:"mode"`
+}
+
+func (f *FilePermissions) Score() bool {
+	return true
+}
+
+func (f *FilePermissions) DefaultString() string {
+	return fmt.Sprintf("File '%s' has permissions %s", f.Path, f.Mode)
+}
+
+type PasswordPolicy struct {
+	aeaconf2.BaseCondition
+	MaxDays    int
+	Enforced   bool
+	MinEntropy float64
+	Lockout    time.Duration
+}
+
+func (p *PasswordPolicy) Score() bool {
+	return true
+}
+
+func (p *PasswordPolicy) DefaultString() string {
+	return fmt.Sprintf("Passwords expire after %d days", p.MaxDays)
+}
+
+type FileOwner struct {
+	aeaconf2.BaseCondition
+	Path  string
+	Owner string `acf:"default=root"`
+	Group string `acf:"optional"`
+}
+
+func (f *FileOwner) Score() bool {
+	return true
+}
+
+func (f *FileOwner) DefaultString() string {
+	return fmt.Sprintf("File '%s' is owned by %s", f.Path, f.Owner)
+}
+
+type UsersAbsent struct {
+	aeaconf2.BaseCondition
+	Users []string
+}
+
+func (u *UsersAbsent) Score() bool {
+	return true
+}
+
+func (u *UsersAbsent) DefaultString() string {
+	return fmt.Sprintf("Users %s don't exist", strings.Join(u.Users, ", "))
+}
+
+type PortsClosed struct {
+	aeaconf2.BaseCondition
+	Ports []int
+	Host  string `acf:"default=localhost"`
+}
+
+func (p *PortsClosed) Score() bool {
+	return true
+}
+
+func (p *PortsClosed) DefaultString() string {
+	return fmt.Sprintf("Ports %v are closed on %s", p.Ports, p.Host)
+}
+
 // add more...
@@ -7,7 +7,7 @@ require github.com/fatih/color v1.15.0
 require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.17 // indirect
-	github.com/pkg/errors v0.9.1 // indirect
+	github.com/pkg/errors v0.9.1
 	golang.org/x/sys v0.6.0 // indirect
 	gopkg.in/ini.v1 v1.67.0
 )
@@ -0,0 +1,136 @@
+package aeaconf2
+
+import (
+	"fmt"
+	"strings"
+)
+
+// isGroupStart reports whether an `any:` or `all:` block is next
+func (p *Parser) isGroupStart() bool {
+	return isKeyword(p.Peek(), "any") || isKeyword(p.Peek(), "all")
+}
+
+// ParseGroup parses an `any:` or `all:` block, whose operands are the
+// lines indented under the line it ends, one condition per line:
+//
+//	any:
+//		ServiceUp "apache2"
+//		all:
+//			ServiceUp "nginx"
+//			PathExists "/etc/nginx"
+//
+// Operands of `any:` are ORed, and those of `all:` ANDed.
+func (p *Parser) ParseGroup() Condition {
+	keyword := p.Consume()
+	name := string(keyword.Lexeme) + ":"
+	p.ExpectTokenType(TokenColon, fmt.Sprintf("expected ':' following '%s'", keyword.Lexeme))
+	if next := p.Peek(); next.Type != NewTokenline && next.Type != TokenEOF {
+		p.Errorf("expected end of line after '%s' (its conditions go on the following lines), found %s", name, next.Describe())
+	}
+
+	outer := p.lineIndent
+	var inner string
+	var first Span
+	var operands []Condition
+	for p.Peek().Type == NewTokenline {
+		indentToken := p.peekNextLineIndent()
+		if indentToken == nil || !isDeeper(outer, string(indentToken.Lexeme)) {
+			if indentToken != nil && !strings.HasPrefix(outer, string(indentToken.Lexeme)) {
+				p.inconsistentIndent(indentToken, outer, keyword)
+			}
+			break
+		}
+
+		indent := string(indentToken.Lexeme)
+		if len(operands) == 0 {
+			inner, first = indent, indentToken.Span
+		} else if indent != inner {
+			p.ErrorWithNotes(
+				CodeIndentation,
+				indentToken.Span,
+				[]Note{{Message: "first condition of '" + name + "' indented here", Span: first}},
+				"expected the conditions of '%s' to be indented alike",
+				name,
+			)
+		}
+
+		p.SkipUntilIndentedBlock()
+		operands = append(operands, p.ParseCondition())
+	}
+
+	if len(operands) == 0 {
+		p.ErrorWithNotes(CodeIndentation, keyword.Span, nil,
+			"expected conditions for '%s' on the following lines, indented further", name)
+	}
+	if len(operands) == 1 {
+		// the operand stands alone, and keeps its own span
+		return operands[0]
+	}
+
+	var cond Condition
+	if string(keyword.Lexeme) == "any" {
+		cond = BuildOrTree(operands)
+	} else {
+		cond = BuildAndTree(operands)
+	}
+	SetConditionSpan(cond, keyword.Span.Join(ConditionSpan(operands[len(operands)-1])))
+	return cond
+}
+
+// peekNextLineIndent returns the indentation of the line after the line
+// break being peeked, skipping blank lines, without consuming anything. It
+// returns nil if that line isn't indented.
+func (p *Parser) peekNextLineIndent() *Token {
+	for i := 1; ; i++ {
+		token := p.peekAt(i)
+		switch token.Type {
+		case NewTokenline:
+			continue
+		case TokenIndent:
+			switch p.peekAt(i + 1).Type {
+			case NewTokenline:
+				// whitespace or a comment only
+				i++
+				continue
+			case TokenEOF:
+				return nil
+			}
+			return token
+		default:
+			return nil
+		}
+	}
+}
+
+// isDeeper reports whether `indent` is nested under `outer`
+func isDeeper(outer string, indent string) bool {
+	return len(indent) > len(outer) && strings.HasPrefix(indent, outer)
+}
+
+// inconsistentIndent reports a line whose indentation can't be compared
+// with that of the line opening a group, e.g. tabs against spaces
+func (p *Parser) inconsistentIndent(indentToken *Token, outer string, keyword *Token) {
+	p.ErrorWithNotes(
+		CodeIndentation,
+		indentToken.Span,
+		[]Note{{Message: fmt.Sprintf("'%s:' is on a line with %s", keyword.Lexeme, describeIndent(outer)), Span: keyword.Span}},
+		"inconsistent indentation: this line has %s, which can't be compared with the line of '%s:'",
+		describeIndent(string(indentToken.Lexeme)),
+		keyword.Lexeme,
+	)
+}
+
+func describeIndent(indent string) string {
+	tabs := strings.Count(indent, "\t")
+	spaces := len(indent) - tabs
+	switch {
+	case indent == "":
+		return "no indentation"
+	case tabs != 0 && spaces != 0:
+		return fmt.Sprintf("%d tab%s and %d space%s", tabs, plural(tabs), spaces, plural(spaces))
+	case tabs != 0:
+		return fmt.Sprintf("%d tab%s", tabs, plural(tabs))
+	default:
+		return fmt.Sprintf("%d space%s", spaces, plural(spaces))
+	}
+}
@@ -0,0 +1,95 @@
+package aeaconf2_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/safinsingh/aeaconf2"
+)
+
+func TestGroups(t *testing.T) {
+	source := `def WebUp = any:
+	ServiceUp "apache2"
+	ServiceUp "nginx"
+
+_: 3
+	PathExists "/var/www"
+	any:
+		ServiceUp "apache2"
+
+		// comments and blank lines don't end a group
+		all:
+			ServiceUp "nginx"
+			PathExists "/etc/nginx" ||
+				PathExists "/usr/local/nginx"
+	ServiceUp "sshd"
+_: 2; ServiceUp "sshd" && all:
+  WebUp
+  PathExistsNot "/var/www/html/index.html"
+"c": 1; PathExists "/"`
+
+	checks, err := buildChecks(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 3 {
+		t.Fatalf("expected 3 checks, got %d", len(checks))
+	}
+
+	want := "((Path '/var/www' exists AND (Service 'apache2' is running OR (Service 'nginx' is running AND " +
+		"(Path '/etc/nginx' exists OR Path '/usr/local/nginx' exists)))) AND Service 'sshd' is running)"
+	if msg := checks[0].Message; msg != want {
+		t.Errorf("expected %q, got %q", want, msg)
+	}
+	group := checks[0].Condition.(*aeaconf2.AndExpr).Lhs.(*aeaconf2.AndExpr).Rhs.(*aeaconf2.OrExpr)
+	if span := group.Span; span.Start.Line != 7 || span.End.Line != 14 {
+		t.Errorf("expected the group's span to cover its block, got %+v", span)
+	}
+
+	want = "(Service 'sshd' is running AND ((Service 'apache2' is running OR Service 'nginx' is running) AND NOT (Path '/var/www/html/index.html' exists)))"
+	if msg := checks[1].Message; msg != want {
+		t.Errorf("expected %q, got %q", want, msg)
+	}
+	if msg := checks[2].Message; msg != "c" {
+		t.Errorf("expected the check after a group to parse, got %q", msg)
+	}
+
+	// a group of one condition is that condition, span and all
+	checks, err = buildChecks("\"d\": 1\n\tany:\n\t\tServiceUp \"sshd\"")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if span := aeaconf2.ConditionSpan(checks[0].Condition); span.Start.Line != 3 || span.Start.Column != 3 {
+		t.Errorf("expected the condition to keep its span, got %+v", span)
+	}
+}
+
+func TestGroupErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		code    string
+		message string
+		line    int
+	}{
+		{"empty", "\"a\": 3\n\tany:\n\"b\": 3; ServiceUp \"sshd\"", aeaconf2.CodeIndentation, "expected conditions for 'any:' on the following lines, indented further", 2},
+		{"not nested", "\"a\": 3\n\tall:\n\tServiceUp \"sshd\"", aeaconf2.CodeIndentation, "expected conditions for 'all:' on the following lines, indented further", 2},
+		{"misaligned", "\"a\": 3\n\tany:\n\t\t\tServiceUp \"sshd\"\n\t\tServiceUp \"ftp\"", aeaconf2.CodeIndentation, "expected the conditions of 'any:' to be indented alike", 4},
+		{"tabs and spaces", "\"a\": 3\n\tany:\n\t\tServiceUp \"sshd\"\n    ServiceUp \"ftp\"", aeaconf2.CodeIndentation, "inconsistent indentation: this line has 4 spaces, which can't be compared with the line of 'any:'", 4},
+		{"trailing tokens", "\"a\": 3\n\tany: ServiceUp \"sshd\"", aeaconf2.CodeSyntax, "expected end of line after 'any:' (its conditions go on the following lines), found name `ServiceUp`", 2},
+		{"missing colon", "\"a\": 3\n\tall\n\t\tServiceUp \"sshd\"", aeaconf2.CodeSyntax, "expected ':', found end of line: expected ':' following 'all'", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := buildChecks(tt.source)
+			var pe *aeaconf2.ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("expected *ParseError, got %v", err)
+			}
+			if pe.Code != tt.code || pe.Message != tt.message || pe.Line != tt.line {
+				t.Errorf("expected %s %q on line %d, got %s %q on line %d", tt.code, tt.message, tt.line, pe.Code, pe.Message, pe.Line)
+			}
+		})
+	}
+}
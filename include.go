@@ -0,0 +1,122 @@
+package aeaconf2
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// sourceSet is every file read while parsing, shared by the parsers of
+// included files
+type sourceSet struct {
+	files map[string]*SourceFile
+	// order in which files were first read, for sorting diagnostics
+	order map[string]int
+	// `// acf:ignore` comments of each file; see Lexer.Ignores
+	ignores map[string]map[int][]string
+}
+
+func newSourceSet() *sourceSet {
+	return &sourceSet{
+		files:   make(map[string]*SourceFile),
+		order:   make(map[string]int),
+		ignores: make(map[string]map[int][]string),
+	}
+}
+
+func (s *sourceSet) add(lexer *Lexer) {
+	name := lexer.File.Name
+	s.files[name] = lexer.File
+	s.order[name] = len(s.order)
+	s.ignores[name] = lexer.Ignores
+}
+
+// before orders diagnostics by file, in the order files were read, then by
+// position
+func (s *sourceSet) before(a Span, b Span) bool {
+	if a.Start.File != b.Start.File {
+		return s.order[a.Start.File] < s.order[b.Start.File]
+	}
+	return a.Start.Offset < b.Start.Offset
+}
+
+// ParseInclude parses an `include "path"` directive, returning the checks
+// of the included file. Paths are relative to the including file, and each
+// file is only included once.
+func (p *Parser) ParseInclude() []*Check {
+	includeToken := p.Consume()
+	pathToken := p.ExpectTokenType(TokenString, "expected a path to include")
+	p.expectEndOfLine("include")
+	span := includeToken.Span.Join(pathToken.Span)
+
+	name := p.resolveInclude(p.Interpolate(pathToken))
+	for i, including := range p.includeStack {
+		if including == name {
+			cycle := append(append([]string{}, p.includeStack[i:]...), name)
+			for j := range cycle {
+				cycle[j] = displayFileName(cycle[j])
+			}
+			p.ErrorWithNotes(CodeIncludeCycle, span, nil, "include cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	if _, ok := p.sources.files[name]; ok {
+		return nil
+	}
+
+	var content []byte
+	var err error
+	if p.FS != nil {
+		content, err = fs.ReadFile(p.FS, name)
+	} else {
+		content, err = os.ReadFile(name)
+	}
+	if err != nil {
+		p.ErrorWithNotes(CodeIncludeNotFound, pathToken.Span, nil, "could not include '%s': %s", displayFileName(name), err)
+	}
+
+	lexer := NewFileLexer(NewSourceFile(name, trimSource(content), 0))
+	p.sources.add(lexer)
+
+	child := NewParser(lexer, p.FuncRegistry)
+	child.Constants = p.Constants
+	child.Macros = p.Macros
+	child.Templates = p.Templates
+	child.FS = p.FS
+	child.Header = p.Header
+	child.sources = p.sources
+	child.includeStack = append(append([]string{}, p.includeStack...), name)
+
+	checks := child.Checks()
+	for _, pe := range child.Errors {
+		pe.AddNote("included here", span)
+	}
+	p.Errors = append(p.Errors, child.Errors...)
+	p.Warnings = append(p.Warnings, child.Warnings...)
+	return checks
+}
+
+// resolveInclude returns the name of an included file, relative to the
+// including one
+func (p *Parser) resolveInclude(name string) string {
+	current := p.Lexer.File.Name
+	if p.FS != nil {
+		// fs.FS paths are always slash-separated and unrooted
+		if path.IsAbs(name) {
+			return strings.TrimPrefix(path.Clean(name), "/")
+		}
+		return path.Join(path.Dir(current), name)
+	}
+	if filepath.IsAbs(name) {
+		return filepath.Clean(name)
+	}
+	return filepath.Join(filepath.Dir(current), name)
+}
+
+func displayFileName(name string) string {
+	if name == "" {
+		return "<checks>"
+	}
+	return name
+}
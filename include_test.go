@@ -0,0 +1,95 @@
+package aeaconf2_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/fatih/color"
+	"github.com/safinsingh/aeaconf2"
+)
+
+func buildFS(fsys fstest.MapFS, fileName string) (*aeaconf2.AeaconfBuilder, []*aeaconf2.Check, error) {
+	ab := aeaconf2.DefaultAeaconfBuilder(fsys[fileName].Data, getFunctionRegistry()).
+		SetFileName(fileName).
+		SetFS(fsys)
+	checks, err := ab.Build()
+	return ab, checks, err
+}
+
+func TestInclude(t *testing.T) {
+	fsys := fstest.MapFS{
+		"rounds/ubuntu.acf": {Data: []byte(`include "../common/ssh.acf"
+include "../common/ssh.acf"
+
+"FTP is disabled": 3; ServiceUpNot "vsftpd"
+"SSH is hardened": 3; SshHardened`)},
+		"common/ssh.acf": {Data: []byte(`include "base.acf"
+
+def SshHardened = FileContains "${sshd_config}" "PermitRootLogin no"
+
+"SSH is running": 2; ServiceUp "sshd"`)},
+		"common/base.acf": {Data: []byte(`let sshd_config = "/etc/ssh/sshd_config"`)},
+	}
+
+	_, checks, err := buildFS(fsys, "rounds/ubuntu.acf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []string
+	for _, check := range checks {
+		messages = append(messages, check.Message)
+	}
+	if got := strings.Join(messages, ", "); got != "SSH is running, FTP is disabled, SSH is hardened" {
+		t.Errorf("unexpected checks: %s", got)
+	}
+	if file := checks[0].Span.Start.File; file != "common/ssh.acf" {
+		t.Errorf("expected included check to be located in its file, got %q", file)
+	}
+	if file := checks[2].Condition.(*FileContains).File; file != "/etc/ssh/sshd_config" {
+		t.Errorf("unexpected argument: %q", file)
+	}
+}
+
+func TestIncludeErrors(t *testing.T) {
+	color.NoColor = true
+
+	fsys := fstest.MapFS{
+		"main.acf":   {Data: []byte("include \"a.acf\"\n\"b\": 3; ServiceUp \"sshd\"")},
+		"a.acf":      {Data: []byte("\"a\": 3; ServiceUpp \"sshd\"\ninclude \"main.acf\"")},
+		"broken.acf": {Data: []byte("include \"missing.acf\"")},
+	}
+
+	_, _, err := buildFS(fsys, "main.acf")
+	var errs aeaconf2.ParseErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", err)
+	}
+
+	unknown := errs[0]
+	if unknown.Code != aeaconf2.CodeUnknownFunction || unknown.Span.Start.File != "a.acf" {
+		t.Errorf("unexpected error: %v", unknown)
+	}
+	rendered := unknown.Render()
+	if !strings.Contains(rendered, "--> a.acf:1:9") || !strings.Contains(rendered, "1 | \"a\": 3; ServiceUpp \"sshd\"") {
+		t.Errorf("expected excerpt from the included file, got:\n%s", rendered)
+	}
+	if note := unknown.Notes[len(unknown.Notes)-1]; note.Message != "included here" || note.Span.Start.File != "main.acf" {
+		t.Errorf("unexpected note: %+v", note)
+	}
+	if !strings.Contains(rendered, "--> main.acf:1:1") {
+		t.Errorf("expected note excerpt from the including file, got:\n%s", rendered)
+	}
+
+	if cycle := errs[1]; cycle.Code != aeaconf2.CodeIncludeCycle || cycle.Message != "include cycle: main.acf -> a.acf -> main.acf" {
+		t.Errorf("unexpected error: %v", cycle)
+	}
+
+	_, _, err = buildFS(fsys, "broken.acf")
+	var pe *aeaconf2.ParseError
+	if !errors.As(err, &pe) || pe.Code != aeaconf2.CodeIncludeNotFound {
+		t.Errorf("expected include-not-found, got %v", err)
+	}
+}
@@ -1,9 +1,13 @@
 package aeaconf2
 
 import (
+	"bytes"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 	"unicode"
+	"unicode/utf8"
 )
 
 type TokenType int
@@ -16,17 +20,29 @@ const (
 	TokenRParen
 	TokenLBracket
 	TokenRBracket
+	TokenLBrace
+	TokenRBrace
 
 	TokenColon
 	TokenSemicolon
+	TokenComma
 	TokenUnderscore
+	TokenEquals
+	TokenEqEq
+	TokenNotEq
 
 	TokenAnd
 	TokenOr
+	TokenXor
+	TokenImplies
+	TokenNot
 
 	TokenString
 	TokenNumber
+	TokenFloat
+	TokenDuration
 	TokenIdent
+	TokenDirective
 
 	TokenEOF
 )
@@ -45,22 +61,46 @@ func (ty TokenType) Str() string {
 		return "TokenLBracket"
 	case TokenRBracket:
 		return "TokenRBracket"
+	case TokenLBrace:
+		return "TokenLBrace"
+	case TokenRBrace:
+		return "TokenRBrace"
 	case TokenColon:
 		return "TokenColon"
 	case TokenSemicolon:
 		return "TokenSemicolon"
+	case TokenComma:
+		return "TokenComma"
 	case TokenUnderscore:
 		return "TokenUnderscore"
+	case TokenEquals:
+		return "TokenEquals"
+	case TokenEqEq:
+		return "TokenEqEq"
+	case TokenNotEq:
+		return "TokenNotEq"
 	case TokenAnd:
 		return "TokenAnd"
 	case TokenOr:
 		return "TokenOr"
+	case TokenXor:
+		return "TokenXor"
+	case TokenImplies:
+		return "TokenImplies"
+	case TokenNot:
+		return "TokenNot"
 	case TokenIdent:
 		return "TokenIdent"
+	case TokenDirective:
+		return "TokenDirective"
 	case TokenString:
 		return "TokenString"
 	case TokenNumber:
 		return "TokenNumber"
+	case TokenFloat:
+		return "TokenFloat"
+	case TokenDuration:
+		return "TokenDuration"
 	case TokenEOF:
 		return "TokenEOF"
 	default:
@@ -68,9 +108,72 @@ func (ty TokenType) Str() string {
 	}
 }
 
+// Describe returns the name of the token type as an image author would know it
+func (ty TokenType) Describe() string {
+	switch ty {
+	case NewTokenline:
+		return "end of line"
+	case TokenIndent:
+		return "indentation"
+	case TokenLParen:
+		return "'('"
+	case TokenRParen:
+		return "')'"
+	case TokenLBracket:
+		return "'['"
+	case TokenRBracket:
+		return "']'"
+	case TokenLBrace:
+		return "'{'"
+	case TokenRBrace:
+		return "'}'"
+	case TokenColon:
+		return "':'"
+	case TokenSemicolon:
+		return "';'"
+	case TokenComma:
+		return "','"
+	case TokenUnderscore:
+		return "placeholder '_'"
+	case TokenEquals:
+		return "'='"
+	case TokenEqEq:
+		return "'=='"
+	case TokenNotEq:
+		return "'!='"
+	case TokenAnd:
+		return "'&&'"
+	case TokenOr:
+		return "'||'"
+	case TokenXor:
+		return "'^^'"
+	case TokenImplies:
+		return "'=>'"
+	case TokenNot:
+		return "'!'"
+	case TokenIdent:
+		return "name"
+	case TokenDirective:
+		return "directive"
+	case TokenString:
+		return "string"
+	case TokenNumber:
+		return "number"
+	case TokenFloat:
+		return "decimal number"
+	case TokenDuration:
+		return "duration"
+	case TokenEOF:
+		return "end of file"
+	default:
+		panic("unknown token type")
+	}
+}
+
 type Token struct {
 	Type   TokenType
 	Lexeme []byte
+	Span   Span
 }
 
 func NewToken(tokenType TokenType, lexeme []byte) *Token {
@@ -84,9 +187,33 @@ func (t *Token) Debug() string {
 	return fmt.Sprintf("Token{type: %s, lexeme: '%s'}", t.Type.Str(), string(t.Lexeme))
 }
 
+// Describe returns the token type, along with its lexeme where that helps
+// (e.g. function name `ServiceUp`)
+func (t *Token) Describe() string {
+	switch t.Type {
+	case TokenIdent, TokenDirective, TokenString, TokenNumber, TokenFloat, TokenDuration:
+		return fmt.Sprintf("%s `%s`", t.Type.Describe(), t.Lexeme)
+	default:
+		return t.Type.Describe()
+	}
+}
+
 func (t *Token) Value() any {
 	if t.Type == TokenString {
-		return string(t.Lexeme[1 : len(t.Lexeme)-1])
+		if t.Lexeme[0] == '`' {
+			return string(t.Lexeme[1 : len(t.Lexeme)-1])
+		}
+
+		body := t.Lexeme[1 : len(t.Lexeme)-1]
+		if bytes.HasPrefix(t.Lexeme, []byte(`"""`)) {
+			body = Dedent(t.Lexeme[3 : len(t.Lexeme)-3])
+		}
+		value, _, _, err := Unescape(body)
+		if err != nil {
+			// should never happen; escapes are checked while lexing
+			panic("invalid escape")
+		}
+		return value
 	} else if t.Type == TokenNumber {
 		num, err := strconv.Atoi(string(t.Lexeme))
 		if err != nil {
@@ -94,39 +221,170 @@ func (t *Token) Value() any {
 			panic("invalid number")
 		}
 		return num
+	} else if t.Type == TokenFloat {
+		num, err := strconv.ParseFloat(string(t.Lexeme), 64)
+		if err != nil {
+			// should never happen
+			panic("invalid float")
+		}
+		return num
+	} else if t.Type == TokenDuration {
+		duration, err := time.ParseDuration(string(t.Lexeme))
+		if err != nil {
+			// should never happen; durations are checked while lexing
+			panic("invalid duration")
+		}
+		return duration
 	} else {
 		return string(t.Lexeme)
 	}
 }
 
+// Unescape decodes the escape sequences in the body of a string literal:
+//
+//	\n \t \r \\ \" \' \xNN \u{N...}
+//
+// For an invalid escape, it also returns the offset and length of the
+// offending sequence within body.
+func Unescape(body []byte) (string, int, int, error) {
+	var sb strings.Builder
+	for i := 0; i < len(body); i++ {
+		if body[i] != '\\' {
+			sb.WriteByte(body[i])
+			continue
+		}
+		if i+1 >= len(body) {
+			return "", i, 1, fmt.Errorf("unfinished escape sequence")
+		}
+
+		switch body[i+1] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case 'r':
+			sb.WriteByte('\r')
+		case '\\', '"', '\'':
+			sb.WriteByte(body[i+1])
+		case 'x':
+			if i+4 > len(body) {
+				return "", i, len(body) - i, fmt.Errorf("invalid escape: '\\x' must be followed by two hex digits")
+			}
+			b, err := strconv.ParseUint(string(body[i+2:i+4]), 16, 8)
+			if err != nil {
+				return "", i, 4, fmt.Errorf("invalid escape: '\\x' must be followed by two hex digits")
+			}
+			sb.WriteByte(byte(b))
+			i += 2
+		case 'u':
+			end := bytes.IndexByte(body[i:], '}')
+			if i+2 >= len(body) || body[i+2] != '{' || end == -1 {
+				return "", i, 2, fmt.Errorf("invalid escape: expected '\\u{...}' with 1 to 6 hex digits")
+			}
+			digits := string(body[i+3 : i+end])
+			r, err := strconv.ParseUint(digits, 16, 32)
+			if err != nil || len(digits) == 0 || len(digits) > 6 {
+				return "", i, end + 1, fmt.Errorf("invalid escape: expected '\\u{...}' with 1 to 6 hex digits")
+			}
+			if !utf8.ValidRune(rune(r)) {
+				return "", i, end + 1, fmt.Errorf("invalid escape: '%s' is not a valid unicode code point", digits)
+			}
+			sb.WriteRune(rune(r))
+			i += end - 1
+		default:
+			return "", i, 2, fmt.Errorf("unknown escape sequence '\\%c'", body[i+1])
+		}
+		i++
+	}
+	return sb.String(), 0, 0, nil
+}
+
+// Dedent prepares the body of a triple-quoted string. A newline directly
+// after the opening quotes is dropped, as is the line holding the closing
+// quotes if it is only indentation. The indentation common to the remaining
+// lines (ignoring blank ones) is then stripped from each of them.
+func Dedent(body []byte) []byte {
+	lines := strings.Split(string(body), "\n")
+	// content on the same line as the opening quotes is left as is
+	first := 1
+	if lines[0] == "" {
+		lines = lines[1:]
+		first = 0
+	}
+	if len(lines) > 1 && strings.TrimLeft(lines[len(lines)-1], " \t") == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	indent := ""
+	found := false
+	for _, line := range lines[min(first, len(lines)):] {
+		if strings.TrimLeft(line, " \t") == "" {
+			continue
+		}
+		lineIndent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if !found {
+			indent = lineIndent
+			found = true
+		}
+		for !strings.HasPrefix(lineIndent, indent) {
+			indent = indent[:len(indent)-1]
+		}
+	}
+
+	for i := min(first, len(lines)); i < len(lines); i++ {
+		if strings.TrimLeft(lines[i], " \t") == "" {
+			lines[i] = ""
+		} else {
+			lines[i] = lines[i][len(indent):]
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
 type Lexer struct {
 	Source     []byte
 	Pos        int
 	LineOffset int
+	File       *SourceFile
+
+	// lint codes suppressed by `// acf:ignore <code>...` comments, keyed by
+	// the line they apply to
+	Ignores map[int][]string
 }
 
 func NewLexer(source []byte, lineOffset int) *Lexer {
-	return &Lexer{Source: source, Pos: 0, LineOffset: lineOffset}
+	return NewFileLexer(NewSourceFile("", source, lineOffset))
+}
+
+func NewFileLexer(file *SourceFile) *Lexer {
+	return &Lexer{
+		Source:     file.Content,
+		Pos:        0,
+		LineOffset: file.LineOffset,
+		File:       file,
+		Ignores:    make(map[int][]string),
+	}
+}
+
+// TokenFrom creates a token of the source between start and the current position
+func (l *Lexer) TokenFrom(tokenType TokenType, start int) *Token {
+	token := NewToken(tokenType, l.Source[start:l.Pos])
+	token.Span = l.File.Span(start, l.Pos)
+	return token
 }
 
 func (l *Lexer) Errorf(format string, a ...any) {
+	l.ErrorAt(CodeInvalidToken, l.Pos, min(l.Pos+1, len(l.Source)), format, a...)
+}
+
+func (l *Lexer) ErrorAt(code string, start int, end int, format string, a ...any) {
 	message := fmt.Sprintf(format, a...)
-	line, column := l.GetSourceVisualLocation()
-	Fatal(STAGE_LEXER, fmt.Sprintf("(line %d, column %d) %s", line, column, message))
+	panic(NewParseError(STAGE_LEXER, code, l.File, l.File.Span(start, end), message))
 }
 
 func (l *Lexer) GetSourceVisualLocation() (int, int) {
-	line := 1
-	column := 1
-	for i := 0; i < l.Pos; i++ {
-		if l.Source[i] == '\n' {
-			line++
-			column = 1
-		} else {
-			column++
-		}
-	}
-	return line + l.LineOffset, column
+	pos := l.File.Position(l.Pos)
+	return pos.Line, pos.Column
 }
 
 func (l *Lexer) ExpectCharacter(ch byte) {
@@ -141,15 +399,15 @@ func (l *Lexer) ExpectCharacter(ch byte) {
 	}
 }
 
-func (l *Lexer) AdvanceToken(tokenType TokenType, lexeme byte) *Token {
+func (l *Lexer) AdvanceToken(tokenType TokenType) *Token {
 	l.Pos++
-	return NewToken(tokenType, []byte{lexeme})
+	return l.TokenFrom(tokenType, l.Pos-1)
 }
 
-func (l *Lexer) AdvanceToken2(tokenType TokenType, currentLexeme byte, expect byte) *Token {
+func (l *Lexer) AdvanceToken2(tokenType TokenType, expect byte) *Token {
 	l.Pos++
 	l.ExpectCharacter(expect)
-	return NewToken(tokenType, []byte{currentLexeme, expect})
+	return l.TokenFrom(tokenType, l.Pos-2)
 }
 
 func (l *Lexer) LexString(ch byte) *Token {
@@ -160,46 +418,175 @@ func (l *Lexer) LexString(ch byte) *Token {
 			break
 		}
 		if l.Source[l.Pos] == '\\' {
+			// skip the escaped character; escapes are checked below
 			l.Pos++
-			if l.Pos >= len(l.Source) {
-				l.Errorf("string not terminated, expected '%c'", ch)
-			}
-			// Only escape for double quotes
-			if l.Source[l.Pos] == '"' {
-				l.Pos++
-			}
-		} else {
+		}
+		l.Pos++
+	}
+	if l.Pos >= len(l.Source) {
+		l.ErrorAt(CodeInvalidToken, initialPos, initialPos+1, "string not terminated, expected '%c'", ch)
+	}
+
+	// past the string before checking escapes, so that recovery resumes
+	// after it (see Parser.Synchronize)
+	l.Pos++
+	if _, offset, length, err := Unescape(l.Source[initialPos+1 : l.Pos-1]); err != nil {
+		start := initialPos + 1 + offset
+		l.ErrorAt(CodeInvalidEscape, start, start+length, "%s", err)
+	}
+	return l.TokenFrom(TokenString, initialPos)
+}
+
+// LexMultilineString lexes a triple-quoted ("""...""") string, which may
+// span lines. Its body is dedented before escapes are decoded.
+func (l *Lexer) LexMultilineString() *Token {
+	initialPos := l.Pos
+	l.Pos += 3
+	for l.Pos < len(l.Source) && !bytes.HasPrefix(l.Source[l.Pos:], []byte(`"""`)) {
+		if l.Source[l.Pos] == '\\' {
+			// skip the escaped character; escapes are checked below
 			l.Pos++
 		}
+		l.Pos++
 	}
 	if l.Pos >= len(l.Source) {
-		l.Errorf("string not terminated, expected '%c'", ch)
+		l.ErrorAt(CodeInvalidToken, initialPos, initialPos+3, `multi-line string not terminated, expected '"""'`)
 	}
+
+	l.Pos += 3
+	if _, offset, length, err := Unescape(l.Source[initialPos+3 : l.Pos-3]); err != nil {
+		start := initialPos + 3 + offset
+		l.ErrorAt(CodeInvalidEscape, start, start+length, "%s", err)
+	}
+	return l.TokenFrom(TokenString, initialPos)
+}
+
+// LexRawString lexes a backtick-delimited string, which may span lines
+// and has no escape sequences
+func (l *Lexer) LexRawString() *Token {
+	initialPos := l.Pos
 	l.Pos++
-	return NewToken(TokenString, l.Source[initialPos:l.Pos])
+	for l.Pos < len(l.Source) && l.Source[l.Pos] != '`' {
+		l.Pos++
+	}
+	if l.Pos >= len(l.Source) {
+		l.ErrorAt(CodeInvalidToken, initialPos, initialPos+1, "raw string not terminated, expected '`'")
+	}
+	l.Pos++
+	return l.TokenFrom(TokenString, initialPos)
+}
+
+// identifiers are letters, digits and underscores; bytes of multi-byte
+// characters are let through so unicode letters work too
+func isIdentByte(b byte) bool {
+	return b == '_' || b >= utf8.RuneSelf || unicode.IsLetter(rune(b)) || unicode.IsDigit(rune(b))
 }
 
 func (l *Lexer) LexIdent() *Token {
 	initialPos := l.Pos
 	l.Pos++
-	for l.Pos < len(l.Source) && !unicode.IsSpace(rune(l.Source[l.Pos])) {
+	for l.Pos < len(l.Source) && isIdentByte(l.Source[l.Pos]) {
 		l.Pos++
 	}
-	return NewToken(TokenIdent, l.Source[initialPos:l.Pos])
+	return l.TokenFrom(TokenIdent, initialPos)
 }
 
-func (l *Lexer) LexNumber() *Token {
+// LexDirective lexes a directive name like `@if`
+func (l *Lexer) LexDirective() *Token {
 	initialPos := l.Pos
 	l.Pos++
+	if l.Pos >= len(l.Source) || !unicode.IsLetter(rune(l.Source[l.Pos])) {
+		l.ErrorAt(CodeInvalidToken, initialPos, l.Pos, "expected a directive name like '@if' following '@'")
+	}
+	for l.Pos < len(l.Source) && isIdentByte(l.Source[l.Pos]) {
+		l.Pos++
+	}
+	return l.TokenFrom(TokenDirective, initialPos)
+}
+
+// peekByte returns the byte n bytes ahead of the current one, or 0 at the
+// end of the source
+func (l *Lexer) peekByte(n int) byte {
+	if l.Pos+n >= len(l.Source) {
+		return 0
+	}
+	return l.Source[l.Pos+n]
+}
+
+func (l *Lexer) lexDigits() {
 	for l.Pos < len(l.Source) && unicode.IsNumber(rune(l.Source[l.Pos])) {
 		l.Pos++
 	}
-	return NewToken(TokenNumber, l.Source[initialPos:l.Pos])
+}
+
+// LexNumber lexes an integer (-12), a decimal number (1.5, 2e-3) or a
+// duration (1h30m, 0.5s)
+func (l *Lexer) LexNumber() *Token {
+	initialPos := l.Pos
+	l.Pos++
+	l.lexDigits()
+	if l.Pos-initialPos == 1 && l.Source[initialPos] == '-' {
+		l.ErrorAt(CodeInvalidToken, initialPos, l.Pos, "expected digits after '-'")
+	}
+
+	tokenType := TokenNumber
+	if l.Pos+1 < len(l.Source) && l.Source[l.Pos] == '.' && unicode.IsNumber(rune(l.Source[l.Pos+1])) {
+		l.Pos++
+		l.lexDigits()
+		tokenType = TokenFloat
+	}
+	// no duration unit starts with 'e', so this is always an exponent
+	if exp := l.peekByte(0); exp == 'e' || exp == 'E' {
+		digits := 1
+		if sign := l.peekByte(1); sign == '+' || sign == '-' {
+			digits = 2
+		}
+		if unicode.IsNumber(rune(l.peekByte(digits))) {
+			l.Pos += digits
+			l.lexDigits()
+			tokenType = TokenFloat
+		}
+	}
+
+	// unit suffixes make a duration, which may have several components
+	if l.Pos < len(l.Source) && isIdentByte(l.Source[l.Pos]) {
+		for l.Pos < len(l.Source) && (isIdentByte(l.Source[l.Pos]) || l.Source[l.Pos] == '.') {
+			l.Pos++
+		}
+		if _, err := time.ParseDuration(string(l.Source[initialPos:l.Pos])); err != nil {
+			l.ErrorAt(CodeInvalidToken, initialPos, l.Pos,
+				"invalid duration '%s': expected e.g. 30s, 5m or 1h30m (units: ns, us, ms, s, m, h)",
+				l.Source[initialPos:l.Pos])
+		}
+		tokenType = TokenDuration
+	}
+	return l.TokenFrom(tokenType, initialPos)
+}
+
+// LexIgnoreDirective records the codes of an `// acf:ignore` comment ending
+// at the current position. A comment on a line of its own applies to the
+// following line; a trailing comment applies to the line it's on.
+func (l *Lexer) LexIgnoreDirective(commentStart int) {
+	const directive = "acf:ignore"
+	body := strings.TrimSpace(string(l.Source[commentStart+2 : l.Pos]))
+	if !strings.HasPrefix(body, directive) {
+		return
+	}
+	codes := strings.FieldsFunc(body[len(directive):], func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+
+	pos := l.File.Position(commentStart)
+	line := pos.Line
+	if len(bytes.TrimSpace(l.Source[commentStart-pos.Column+1:commentStart])) == 0 {
+		line++
+	}
+	l.Ignores[line] = append(l.Ignores[line], codes...)
 }
 
 func (l *Lexer) NextToken() *Token {
 	if l.Pos >= len(l.Source) {
-		return NewToken(TokenEOF, []byte{})
+		return l.TokenFrom(TokenEOF, l.Pos)
 	}
 
 	if l.Pos == 0 || l.Source[l.Pos-1] == '\n' {
@@ -208,7 +595,7 @@ func (l *Lexer) NextToken() *Token {
 			l.Pos++
 		}
 		if l.Pos-initialPos > 0 {
-			return NewToken(TokenIndent, l.Source[initialPos:l.Pos])
+			return l.TokenFrom(TokenIndent, initialPos)
 		}
 	}
 
@@ -216,27 +603,55 @@ func (l *Lexer) NextToken() *Token {
 
 	switch ch {
 	case '\n':
-		return l.AdvanceToken(NewTokenline, ch)
+		return l.AdvanceToken(NewTokenline)
 	case '(':
-		return l.AdvanceToken(TokenLParen, ch)
+		return l.AdvanceToken(TokenLParen)
 	case ')':
-		return l.AdvanceToken(TokenRParen, ch)
+		return l.AdvanceToken(TokenRParen)
 	case '[':
-		return l.AdvanceToken(TokenLBracket, ch)
+		return l.AdvanceToken(TokenLBracket)
 	case ']':
-		return l.AdvanceToken(TokenRBracket, ch)
+		return l.AdvanceToken(TokenRBracket)
+	case '{':
+		return l.AdvanceToken(TokenLBrace)
+	case '}':
+		return l.AdvanceToken(TokenRBrace)
 	case ':':
-		return l.AdvanceToken(TokenColon, ch)
+		return l.AdvanceToken(TokenColon)
 	case ';':
-		return l.AdvanceToken(TokenSemicolon, ch)
+		return l.AdvanceToken(TokenSemicolon)
+	case ',':
+		return l.AdvanceToken(TokenComma)
 	case '_':
-		return l.AdvanceToken(TokenUnderscore, ch)
+		return l.AdvanceToken(TokenUnderscore)
+	case '=':
+		if l.peekByte(1) == '=' {
+			return l.AdvanceToken2(TokenEqEq, '=')
+		}
+		if l.peekByte(1) == '>' {
+			return l.AdvanceToken2(TokenImplies, '>')
+		}
+		return l.AdvanceToken(TokenEquals)
 	case '&':
-		return l.AdvanceToken2(TokenAnd, ch, '&')
+		return l.AdvanceToken2(TokenAnd, '&')
 	case '|':
-		return l.AdvanceToken2(TokenOr, ch, '|')
+		return l.AdvanceToken2(TokenOr, '|')
+	case '^':
+		return l.AdvanceToken2(TokenXor, '^')
+	case '!':
+		if l.peekByte(1) == '=' {
+			return l.AdvanceToken2(TokenNotEq, '=')
+		}
+		return l.AdvanceToken(TokenNot)
+	case '@':
+		return l.LexDirective()
 	case '"', '\'':
+		if bytes.HasPrefix(l.Source[l.Pos:], []byte(`"""`)) {
+			return l.LexMultilineString()
+		}
 		return l.LexString(ch)
+	case '`':
+		return l.LexRawString()
 	}
 
 	if unicode.IsLetter(rune(ch)) {
@@ -250,10 +665,12 @@ func (l *Lexer) NextToken() *Token {
 	// skip comments entirely
 	if ch == '/' {
 		if l.Pos+1 < len(l.Source) && l.Source[l.Pos+1] == '/' {
+			commentStart := l.Pos
 			l.Pos += 2 // skip second '/'
 			for l.Pos < len(l.Source) && l.Source[l.Pos] != '\n' {
 				l.Pos++
 			}
+			l.LexIgnoreDirective(commentStart)
 			return l.NextToken()
 		}
 	}
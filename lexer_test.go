@@ -0,0 +1,95 @@
+package aeaconf2_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/safinsingh/aeaconf2"
+)
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		literal string
+		value   string
+	}{
+		{`"a\"b"`, `a"b`},
+		{`'it\'s'`, `it's`},
+		{`"tab\there\nnewline"`, "tab\there\nnewline"},
+		{`"back\\slash"`, `back\slash`},
+		{`"\x41\x7e"`, "A~"},
+		{`"\u{1F600} \u{e9}"`, "\U0001F600 é"},
+	}
+
+	for _, tt := range tests {
+		token := aeaconf2.NewLexer([]byte(tt.literal), 0).NextToken()
+		if value := token.Value().(string); value != tt.value {
+			t.Errorf("%s: expected %q, got %q", tt.literal, tt.value, value)
+		}
+	}
+}
+
+func TestOperatorTokens(t *testing.T) {
+	lexer := aeaconf2.NewLexer([]byte("&& || ^^ => == != = !"), 0)
+	want := []aeaconf2.TokenType{
+		aeaconf2.TokenAnd, aeaconf2.TokenOr, aeaconf2.TokenXor, aeaconf2.TokenImplies,
+		aeaconf2.TokenEqEq, aeaconf2.TokenNotEq, aeaconf2.TokenEquals, aeaconf2.TokenNot, aeaconf2.TokenEOF,
+	}
+	for _, ty := range want {
+		if token := lexer.NextToken(); token.Type != ty {
+			t.Errorf("expected %s, got %s", ty.Str(), token.Type.Str())
+		}
+	}
+}
+
+func TestInvalidStringEscapes(t *testing.T) {
+	tests := []struct {
+		source string
+		column int
+	}{
+		{`"a": 3; ServiceUp "ss\qd"`, 22},
+		{`"a": 3; ServiceUp "\x4"`, 20},
+		{`"a": 3; ServiceUp "\u{110000}"`, 20},
+		{`"a": 3; ServiceUp "\u1234"`, 20},
+	}
+
+	for _, tt := range tests {
+		_, err := buildChecks(tt.source)
+		var pe *aeaconf2.ParseError
+		if !errors.As(err, &pe) {
+			t.Fatalf("%s: expected *ParseError, got %v", tt.source, err)
+		}
+		if pe.Code != aeaconf2.CodeInvalidEscape || pe.Column != tt.column {
+			t.Errorf("%s: expected invalid escape at column %d, got %s", tt.source, tt.column, pe)
+		}
+	}
+}
+
+func TestRawAndMultilineStrings(t *testing.T) {
+	source := "\"a\": 3\n" +
+		"\tFileContains `/etc/ssh/sshd_config` `^PermitRootLogin\\s+\"no\"$`\n" +
+		"\tFileContains \"/etc/motd\" \"\"\"\n" +
+		"\t\tWelcome!\n" +
+		"\t\t  \\tindented\n" +
+		"\n" +
+		"\t\tbye\n" +
+		"\t\"\"\"\n" +
+		"\tServiceUp \"sshd\""
+
+	checks, err := buildChecks(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	and := checks[0].Condition.(*aeaconf2.AndExpr)
+	first := and.Lhs.(*aeaconf2.AndExpr).Lhs.(*FileContains)
+	second := and.Lhs.(*aeaconf2.AndExpr).Rhs.(*FileContains)
+	if first.Value != `^PermitRootLogin\s+"no"$` {
+		t.Errorf("unexpected raw string value %q", first.Value)
+	}
+	if second.Value != "Welcome!\n  \tindented\n\nbye" {
+		t.Errorf("unexpected multi-line string value %q", second.Value)
+	}
+	if service := and.Rhs.(*ServiceUp).Service; service != "sshd" {
+		t.Errorf("expected condition after multi-line string to parse, got %q", service)
+	}
+}
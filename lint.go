@@ -0,0 +1,286 @@
+package aeaconf2
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Stable lint codes. Each may be disabled through AeaconfBuilder.DisableLints,
+// or suppressed for a single line with an `// acf:ignore <code>` comment.
+const (
+	LintDuplicateMessage    = "duplicate-message"
+	LintDuplicateCondition  = "duplicate-condition"
+	LintContradiction       = "contradiction"
+	LintTautology           = "tautology"
+	LintNegativePlaceholder = "negative-placeholder"
+	LintUnreachableHint     = "unreachable-hint"
+	LintEmptyHint           = "empty-hint"
+)
+
+var LintCodes = []string{
+	LintDuplicateMessage,
+	LintDuplicateCondition,
+	LintContradiction,
+	LintTautology,
+	LintNegativePlaceholder,
+	LintUnreachableHint,
+	LintEmptyHint,
+}
+
+// Lint reports non-fatal problems with parsed checks, along with any
+// warnings recorded while parsing. Codes in `disabled` and those suppressed
+// by `// acf:ignore` comments are left out.
+func (p *Parser) Lint(checks []*Check, disabled map[string]bool) []*Diagnostic {
+	warnings := append([]*Diagnostic{}, p.Warnings...)
+	warn := func(code string, span Span, notes []Note, format string, a ...any) {
+		warnings = append(warnings, &Diagnostic{
+			Severity: SeverityWarning,
+			Stage:    STAGE_LINT,
+			Code:     code,
+			Message:  fmt.Sprintf(format, a...),
+			Span:     span,
+			Notes:    notes,
+			source:   p.Lexer.File,
+			files:    p.sources.files,
+		})
+	}
+
+	messages := make(map[string]*Check)
+	conditions := make(map[string]*Check)
+	for _, check := range checks {
+		if first, ok := messages[check.Message]; ok {
+			warn(LintDuplicateMessage, check.Span, []Note{{Message: "first used here", Span: first.Span}},
+				"check message '%s' is used more than once", check.Message)
+		} else {
+			messages[check.Message] = check
+		}
+
+		key := conditionKey(check.Condition)
+		if check.Precondition != nil {
+			// the same condition under different preconditions is fine
+			key += " when " + conditionKey(check.Precondition)
+		}
+		if first, ok := conditions[key]; ok {
+			// at the check, like other check-level lints, so that `acf:ignore`
+			// on its header applies however many lines it has
+			warn(LintDuplicateCondition, check.Span,
+				[]Note{{Message: "same condition as check '" + first.Message + "'", Span: ConditionSpan(first.Condition)}},
+				"check '%s' has the same condition as an earlier check", check.Message)
+		} else {
+			conditions[key] = check
+		}
+
+		if check.Points < 0 && check.MessageEmpty {
+			warn(LintNegativePlaceholder, check.Span, nil,
+				"penalty uses a generated message ('%s'), which describes the condition rather than the penalty",
+				check.Message)
+		}
+
+		lintCondition(check.Condition, warn, false)
+		if check.Precondition != nil {
+			lintCondition(check.Precondition, warn, false)
+		}
+		if check.Hint != "" && neverFails(check.Condition) {
+			warn(LintUnreachableHint, check.Span, nil,
+				"hint for check '%s' can never be shown: its condition can never fail", check.Message)
+		}
+	}
+
+	var kept []*Diagnostic
+	for _, w := range warnings {
+		if !disabled[w.Code] && !p.isIgnored(w) {
+			kept = append(kept, w)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		return p.sources.before(kept[i].Span, kept[j].Span)
+	})
+	return kept
+}
+
+func (p *Parser) isIgnored(d *Diagnostic) bool {
+	for _, code := range p.sources.ignores[d.Span.Start.File][d.Span.Start.Line] {
+		if code == d.Code {
+			return true
+		}
+	}
+	return false
+}
+
+type warnFunc func(code string, span Span, notes []Note, format string, a ...any)
+
+// lintCondition reports contradictions, tautologies and unreachable hints
+// in a condition tree. hintsReported is set once an enclosing condition was
+// found to never fail, so that its hints are only reported once.
+func lintCondition(cond Condition, warn warnFunc, hintsReported bool) {
+	if !hintsReported && neverFails(cond) {
+		forEachHint(cond, func(hinted Condition) {
+			warn(LintUnreachableHint, ConditionSpan(hinted), nil,
+				"hint '%s' can never be shown: the condition it explains can never fail", baseCondition(hinted).Hint)
+		})
+		hintsReported = true
+	}
+
+	switch c := cond.(type) {
+	case *AndExpr:
+		if pos, neg, ok := complementaryPair(flatten(c)); ok {
+			warn(LintContradiction, ConditionSpan(c), []Note{{Message: "negated here", Span: ConditionSpan(neg)}},
+				"condition can never pass: '%s' is required both to hold and not to hold", pos.DefaultString())
+		}
+	case *OrExpr:
+		if pos, neg, ok := complementaryPair(flatten(c)); ok {
+			warn(LintTautology, ConditionSpan(c), []Note{{Message: "negated here", Span: ConditionSpan(neg)}},
+				"condition can never fail: either '%s' holds or it doesn't", pos.DefaultString())
+		}
+	case *ThresholdExpr:
+		if c.neverPasses() {
+			warn(LintContradiction, ConditionSpan(c), nil,
+				"condition can never pass: it requires %s %d of only %d conditions", c.Kind, c.Count, len(c.Conditions))
+		}
+		if c.neverFails() {
+			warn(LintTautology, ConditionSpan(c), nil,
+				"condition can never fail: %s %d of %d conditions always hold", c.Kind, c.Count, len(c.Conditions))
+		}
+	}
+
+	for _, operand := range flatten(cond) {
+		if operand == cond {
+			// not an operator; look inside instead
+			for _, child := range childConditions(cond) {
+				lintCondition(child, warn, hintsReported)
+			}
+			return
+		}
+		lintCondition(operand, warn, hintsReported)
+	}
+}
+
+// flatten collects the operands of a chain of the same operator, e.g.
+// `A && (B && C)` yields A, B and C
+func flatten(cond Condition) []Condition {
+	switch c := cond.(type) {
+	case *AndExpr:
+		var operands []Condition
+		for _, operand := range []Condition{c.Lhs, c.Rhs} {
+			if and, ok := operand.(*AndExpr); ok {
+				operands = append(operands, flatten(and)...)
+			} else {
+				operands = append(operands, operand)
+			}
+		}
+		return operands
+	case *OrExpr:
+		var operands []Condition
+		for _, operand := range []Condition{c.Lhs, c.Rhs} {
+			if or, ok := operand.(*OrExpr); ok {
+				operands = append(operands, flatten(or)...)
+			} else {
+				operands = append(operands, operand)
+			}
+		}
+		return operands
+	default:
+		return []Condition{cond}
+	}
+}
+
+// complementaryPair finds an operand alongside its own negation
+func complementaryPair(operands []Condition) (Condition, Condition, bool) {
+	keys := make(map[string]Condition)
+	for _, operand := range operands {
+		keys[conditionKey(operand)] = operand
+	}
+	for _, operand := range operands {
+		if not, ok := operand.(*NotFunc); ok {
+			if pos, ok := keys[conditionKey(not.Func)]; ok {
+				return pos, not, true
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+// neverFails reports whether a condition holds no matter what, i.e. it is
+// (or requires only) tautologies
+func neverFails(cond Condition) bool {
+	switch c := cond.(type) {
+	case *AndExpr:
+		return neverFails(c.Lhs) && neverFails(c.Rhs)
+	case *ThresholdExpr:
+		return c.neverFails()
+	case *OrExpr:
+		operands := flatten(c)
+		if _, _, ok := complementaryPair(operands); ok {
+			return true
+		}
+		for _, operand := range operands {
+			if neverFails(operand) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// forEachHint calls fn for every hinted condition in a tree
+func forEachHint(cond Condition, fn func(Condition)) {
+	if base := baseCondition(cond); base != nil && base.Hint != "" {
+		fn(cond)
+	}
+	for _, child := range childConditions(cond) {
+		forEachHint(child, fn)
+	}
+}
+
+// childConditions returns the conditions directly nested in cond
+func childConditions(cond Condition) []Condition {
+	val := reflect.ValueOf(cond)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+
+	var children []Condition
+	for i := 0; i < val.NumField(); i++ {
+		switch field := val.Field(i).Interface().(type) {
+		case Condition:
+			if field != nil {
+				children = append(children, field)
+			}
+		case []Condition:
+			children = append(children, field...)
+		}
+	}
+	return children
+}
+
+// conditionKey identifies a condition tree by its types and arguments,
+// ignoring hints and source spans
+func conditionKey(cond Condition) string {
+	val := reflect.ValueOf(cond)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	ty := val.Type()
+
+	var parts []string
+	for i := 0; i < val.NumField(); i++ {
+		if ty.Field(i).Type == reflect.TypeOf(BaseCondition{}) {
+			continue
+		}
+		field := val.Field(i)
+		if child, ok := field.Interface().(Condition); ok && child != nil {
+			parts = append(parts, conditionKey(child))
+		} else if children, ok := field.Interface().([]Condition); ok {
+			var keys []string
+			for _, child := range children {
+				keys = append(keys, conditionKey(child))
+			}
+			parts = append(parts, "["+strings.Join(keys, ", ")+"]")
+		} else {
+			parts = append(parts, fmt.Sprintf("%#v", field.Interface()))
+		}
+	}
+	return fmt.Sprintf("%s(%s)", ty.Name(), strings.Join(parts, ", "))
+}
@@ -0,0 +1,62 @@
+package aeaconf2_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/safinsingh/aeaconf2"
+)
+
+func lintCodes(t *testing.T, ab *aeaconf2.AeaconfBuilder) []string {
+	if _, err := ab.Build(); err != nil {
+		t.Fatal(err)
+	}
+	var codes []string
+	for _, w := range ab.Warnings {
+		codes = append(codes, w.Code)
+	}
+	return codes
+}
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		codes  []string
+	}{
+		{"clean", "\"a\": 3; ServiceUp \"sshd\"\n\"b\": 3; ServiceUp \"ftp\"", nil},
+		{"duplicate message", "\"a\": 3; ServiceUp \"sshd\"\n\"a\": 3; ServiceUp \"ftp\"", []string{aeaconf2.LintDuplicateMessage}},
+		{"duplicate condition", "\"a\": 3; ServiceUp \"sshd\" [\"x\"]\n\"b\": 3; ServiceUp \"sshd\"", []string{aeaconf2.LintDuplicateCondition}},
+		{"contradiction", "\"a\": 3; ServiceUp \"sshd\" && PathExists \"/\" && ServiceUpNot \"sshd\"", []string{aeaconf2.LintContradiction}},
+		{"tautology", "\"a\": 3\n\tPathExists \"/\"\n\t(ServiceUp \"sshd\" || ServiceUpNot \"sshd\")", []string{aeaconf2.LintTautology}},
+		{"impossible threshold", "\"a\": 3; exactly 3 (ServiceUp \"sshd\", ServiceUp \"ftp\")", []string{aeaconf2.LintContradiction}},
+		{"trivial threshold", "\"a\": 3; atmost 2 (ServiceUp \"sshd\", ServiceUp \"ftp\")", []string{aeaconf2.LintTautology}},
+		{"duplicate threshold", "\"a\": 3; atleast 1 (ServiceUp \"sshd\")\n\"b\": 3; atleast 1 (ServiceUp \"sshd\")", []string{aeaconf2.LintDuplicateCondition}},
+		{"negative placeholder", "_: -3; ServiceUp \"telnet\"", []string{aeaconf2.LintNegativePlaceholder}},
+		{"unreachable hint", "\"a\": 3; ServiceUp \"sshd\" [\"x\"] || ServiceUpNot \"sshd\"", []string{aeaconf2.LintUnreachableHint, aeaconf2.LintTautology}},
+		{"empty hint", "\"a\": 3 [\" \"]; ServiceUp \"sshd\"", []string{aeaconf2.LintEmptyHint}},
+		{"ignored", "// acf:ignore duplicate-message\n\"a\": 3; ServiceUp \"sshd\"\n\"a\": 3; ServiceUp \"ftp\" // acf:ignore duplicate-message", nil},
+		{"ignored multi-line", "\"a\": 3; ServiceUp \"sshd\"\n// acf:ignore duplicate-condition\n\"b\": 3\n\tServiceUp \"sshd\"\n\"c\": 3; ServiceUp \"ftp\"\n\"d\": 3 // acf:ignore duplicate-condition\n\tServiceUp \"ftp\"", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codes := lintCodes(t, aeaconf2.DefaultAeaconfBuilder([]byte(tt.source), getFunctionRegistry()))
+			if fmt.Sprint(codes) != fmt.Sprint(tt.codes) {
+				t.Errorf("expected %v, got %v", tt.codes, codes)
+			}
+		})
+	}
+}
+
+func TestDisableLints(t *testing.T) {
+	source := "\"a\": 3; ServiceUp \"sshd\"\n\"a\": 3; ServiceUp \"sshd\""
+	ab := aeaconf2.DefaultAeaconfBuilder([]byte(source), getFunctionRegistry()).
+		DisableLints(aeaconf2.LintDuplicateMessage, aeaconf2.LintDuplicateCondition).
+		EnableLints(aeaconf2.LintDuplicateCondition)
+
+	codes := lintCodes(t, ab)
+	if fmt.Sprint(codes) != fmt.Sprint([]string{aeaconf2.LintDuplicateCondition}) {
+		t.Errorf("expected only %s, got %v", aeaconf2.LintDuplicateCondition, codes)
+	}
+}
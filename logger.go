@@ -17,24 +17,30 @@ const (
 	STAGE_LEXER
 	STAGE_PARSER
 	STAGE_DISTRIBUTION
+	STAGE_LINT
 )
 
-func Fatal(stage CompilerStage, message string) {
-	var stageStr string
+func (stage CompilerStage) String() string {
 	switch stage {
 	case STAGE_PRE:
-		stageStr = "pre"
+		return "pre"
 	case STAGE_INI:
-		stageStr = "ini parser"
+		return "ini parser"
 	case STAGE_LEXER:
-		stageStr = "lexer"
+		return "lexer"
 	case STAGE_PARSER:
-		stageStr = "parser"
+		return "parser"
 	case STAGE_DISTRIBUTION:
-		stageStr = "point distribution"
+		return "point distribution"
+	case STAGE_LINT:
+		return "lint"
+	default:
+		return "unknown"
 	}
+}
 
-	fmt.Fprintf(os.Stderr, "[%s] FATAL: %s\n", stageStr, message)
+func Fatal(stage CompilerStage, message string) {
+	fmt.Fprintf(os.Stderr, "[%s] FATAL: %s\n", stage, message)
 	os.Exit(1)
 }
 
@@ -75,6 +81,36 @@ func DebugCondition1(cond Condition, indentLevel int) string {
 			col(indent+"}"),
 			formatHint(c.Hint),
 		)
+	case *XorExpr:
+		return fmt.Sprintf(
+			"%s \n%s \n%s \n%s%s",
+			col(indent+"XOR {"),
+			DebugCondition1(c.Lhs, indentLevel+1)+col(","),
+			DebugCondition1(c.Rhs, indentLevel+1),
+			col(indent+"}"),
+			formatHint(c.Hint),
+		)
+	case *ImpliesExpr:
+		return fmt.Sprintf(
+			"%s \n%s \n%s \n%s%s",
+			col(indent+"IMPLIES {"),
+			DebugCondition1(c.Lhs, indentLevel+1)+col(","),
+			DebugCondition1(c.Rhs, indentLevel+1),
+			col(indent+"}"),
+			formatHint(c.Hint),
+		)
+	case *ThresholdExpr:
+		var operands []string
+		for _, operand := range c.Conditions {
+			operands = append(operands, DebugCondition1(operand, indentLevel+1))
+		}
+		return fmt.Sprintf(
+			"%s \n%s \n%s%s",
+			col(fmt.Sprintf("%s%s %d {", indent, strings.ToUpper(c.Kind.Keyword()), c.Count)),
+			strings.Join(operands, col(",")+" \n"),
+			col(indent+"}"),
+			formatHint(c.Hint),
+		)
 	case *NotFunc:
 		return fmt.Sprintf(
 			"%s \n%s \n%s%s",
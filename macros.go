@@ -0,0 +1,226 @@
+package aeaconf2
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Macro is a top-level `def Name = <condition>` definition. Each use of
+// Name expands to a fresh copy of the condition.
+//
+// The condition may be written on the same line, or as an indented block
+// whose lines are ANDed, as in a check:
+//
+//	def SshHardened =
+//		FileContains "/etc/ssh/sshd_config" "PermitRootLogin no"
+//		ServiceUp "sshd"
+type Macro struct {
+	Name string
+	// tokens of the condition, replayed through the parser on each use
+	Body []*Token
+	// source of the whole definition
+	Span Span
+
+	used bool
+}
+
+// replay is a captured token stream, ending in an EOF token
+type replay struct {
+	tokens []*Token
+	pos    int
+	eof    *Token
+	// parser state before the replay started, restored after it
+	previous       *Token
+	lookahead      *Token
+	lookaheadValid bool
+	pending        []*Token
+	lineIndent     string
+}
+
+func (r *replay) next() *Token {
+	if r.pos == len(r.tokens) {
+		return r.eof
+	}
+	token := r.tokens[r.pos]
+	r.pos++
+	return token
+}
+
+// ParseDef parses a `def Name = <condition>` definition, capturing the
+// condition's tokens for ExpandMacro
+func (p *Parser) ParseDef() {
+	defToken := p.Consume()
+	nameToken := p.expectDefinitionName(defToken)
+	name := string(nameToken.Lexeme)
+	p.ExpectTokenType(TokenEquals, fmt.Sprintf("expected '=' following 'def %s'", name))
+
+	if _, ok := p.FuncRegistry[name]; ok {
+		p.ErrorAt(nameToken.Span, "'%s' is already a function", name)
+	}
+	if base, ok := strings.CutSuffix(name, "Not"); ok {
+		if _, ok := p.FuncRegistry[base]; ok {
+			p.ErrorAt(nameToken.Span, "'%s' is already a function (the negation of '%s')", name, base)
+		}
+	}
+	if existing, ok := p.Macros[name]; ok {
+		p.ErrorWithNotes(CodeDuplicateDefinition, nameToken.Span, []Note{{Message: "first defined here", Span: existing.Span}},
+			"'%s' is already defined", name)
+	}
+
+	body, span := p.captureBlock(defToken.Span.Join(p.Previous.Span))
+	if len(body) == 0 {
+		p.ErrorAt(span, "expected a condition for definition '%s'", name)
+	}
+
+	p.Macros[name] = &Macro{Name: name, Body: body, Span: span}
+}
+
+// captureBlock consumes the tokens up to the next non-indented line, like
+// the block of a check, returning them and `span` extended over them. No
+// tokens are returned if the block has no content.
+func (p *Parser) captureBlock(span Span) ([]*Token, Span) {
+	var body []*Token
+	empty := true
+	for {
+		token := p.Peek()
+		if token.Type == TokenEOF {
+			break
+		}
+		p.Consume()
+		if token.Type == NewTokenline {
+			if next := p.Peek(); next.Type != TokenIndent && next.Type != NewTokenline {
+				break
+			}
+		} else if token.Type != TokenIndent {
+			span = span.Join(token.Span)
+			empty = false
+		}
+		body = append(body, token)
+	}
+
+	if empty {
+		return nil, span
+	}
+	return body, span
+}
+
+// pushReplay makes the parser read `tokens` until popReplay, after which
+// it continues where it left off
+func (p *Parser) pushReplay(tokens []*Token, end Position) {
+	p.replays = append(p.replays, &replay{
+		tokens:         tokens,
+		eof:            &Token{Type: TokenEOF, Span: Span{Start: end, End: end}},
+		previous:       p.Previous,
+		lookahead:      p.Lookahead,
+		lookaheadValid: p.LookaheadValid,
+		pending:        p.pending,
+		lineIndent:     p.lineIndent,
+	})
+	p.LookaheadValid = false
+	p.pending = nil
+	// captured tokens start at the beginning of a top-level line
+	p.lineIndent = ""
+}
+
+func (p *Parser) popReplay() {
+	r := p.replays[len(p.replays)-1]
+	p.replays = p.replays[:len(p.replays)-1]
+	p.restoreReplayState(r)
+}
+
+func (p *Parser) restoreReplayState(r *replay) {
+	p.Previous = r.previous
+	p.Lookahead = r.lookahead
+	p.LookaheadValid = r.lookaheadValid
+	p.pending = r.pending
+	p.lineIndent = r.lineIndent
+}
+
+// resetReplays abandons every replay after an error, returning to the
+// source being lexed
+func (p *Parser) resetReplays() {
+	if len(p.replays) != 0 {
+		p.restoreReplayState(p.replays[0])
+	}
+	p.replays = nil
+	p.expanding = nil
+	p.scopes = nil
+}
+
+// ExpandMacro parses a fresh copy of a macro's condition, used at `span`
+func (p *Parser) ExpandMacro(macro *Macro, span Span) Condition {
+	for i, outer := range p.expanding {
+		if outer == macro {
+			var cycle []string
+			for _, m := range p.expanding[i:] {
+				cycle = append(cycle, m.Name)
+			}
+			p.ErrorWithNotes(CodeCyclicDefinition, span, []Note{{Message: "defined here", Span: macro.Span}},
+				"'%s' is defined in terms of itself: %s -> %s", macro.Name, strings.Join(cycle, " -> "), macro.Name)
+		}
+	}
+	macro.used = true
+
+	defer func() {
+		// errors in the body point into the definition; say where it was used
+		r := recover()
+		if pe, ok := r.(*ParseError); ok && span != macro.Span {
+			pe.AddNote(fmt.Sprintf("in '%s', used here", macro.Name), span)
+		}
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	p.pushReplay(macro.Body, macro.Span.End)
+	p.expanding = append(p.expanding, macro)
+	// names in the body can't see the parameters of templates it's used in
+	scopes := p.scopes
+	p.scopes = nil
+
+	var cond Condition
+	if p.Peek().Type == NewTokenline {
+		var andedConditions []Condition
+		for p.SkipUntilIndentedBlock() {
+			andedConditions = append(andedConditions, p.ParseCondition())
+		}
+		cond = BuildAndTree(andedConditions)
+	} else {
+		cond = p.ParseCondition()
+		if p.SkipUntilIndentedBlock() {
+			p.ErrorWithNotes(CodeSyntax, p.currentSpan(), []Note{{Message: "in definition of '" + macro.Name + "'", Span: macro.Span}},
+				"expected end of definition, found %s", p.Peek().Describe())
+		}
+	}
+
+	p.popReplay()
+	p.expanding = p.expanding[:len(p.expanding)-1]
+	p.scopes = scopes
+
+	// the expansion stands in for the name it was used by
+	SetConditionSpan(cond, span)
+	return cond
+}
+
+// checkUnusedMacros expands every macro no check has used, so that errors
+// in their conditions are still reported
+func (p *Parser) checkUnusedMacros() {
+	var unused []*Macro
+	for _, macro := range p.Macros {
+		if !macro.used {
+			unused = append(unused, macro)
+		}
+	}
+	sort.Slice(unused, func(i, j int) bool {
+		return unused[i].Span.Start.Offset < unused[j].Span.Start.Offset
+	})
+
+	for _, macro := range unused {
+		if pe := catchParseError(func() { p.ExpandMacro(macro, macro.Span) }); pe != nil {
+			pe.files = p.sources.files
+			p.Errors = append(p.Errors, pe)
+			p.resetReplays()
+		}
+	}
+}
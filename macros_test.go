@@ -0,0 +1,97 @@
+package aeaconf2_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/safinsingh/aeaconf2"
+)
+
+func TestMacros(t *testing.T) {
+	source := `def SshHardened = FileContains "/etc/ssh/sshd_config" "PermitRootLogin no" && ServiceUp "sshd"
+def WebUp =
+	ServiceUp "apache2" ||
+	ServiceUp "nginx"
+	SshHardened
+
+_: 3; SshHardened
+"Web server is up": 3 ["hint"]; WebUp
+"SSH is off": 2; SshHardenedNot`
+
+	checks, err := buildChecks(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 3 {
+		t.Fatalf("expected 3 checks, got %d", len(checks))
+	}
+
+	if msg := checks[0].Message; msg != "(File '/etc/ssh/sshd_config' contains 'PermitRootLogin no' AND Service 'sshd' is running)" {
+		t.Errorf("unexpected generated message: %q", msg)
+	}
+
+	and, ok := checks[1].Condition.(*aeaconf2.AndExpr)
+	if !ok {
+		t.Fatalf("expected the block's lines to be ANDed, got %T", checks[1].Condition)
+	}
+	if _, ok := and.Lhs.(*aeaconf2.OrExpr); !ok {
+		t.Errorf("expected the hanging line to continue an OR, got %T", and.Lhs)
+	}
+
+	not, ok := checks[2].Condition.(*aeaconf2.NotFunc)
+	if !ok {
+		t.Fatalf("expected negated macro, got %T", checks[2].Condition)
+	}
+	// every use expands a fresh copy
+	if not.Func == checks[0].Condition {
+		t.Error("expected separate expansions of the same macro")
+	}
+
+	// the expansion is located at its use, not its definition
+	if span := aeaconf2.ConditionSpan(checks[0].Condition); span.Start.Line != 7 {
+		t.Errorf("expected expansion span on line 7, got %+v", span)
+	}
+}
+
+func TestMacroErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		code    string
+		message string
+		line    int
+	}{
+		{"cycle", "def A = B || PathExists \"/\"\ndef B = ServiceUp \"sshd\" && A\n\"a\": 3; A", aeaconf2.CodeCyclicDefinition, "'A' is defined in terms of itself: A -> B -> A", 2},
+		{"self reference", "def A = !A", aeaconf2.CodeCyclicDefinition, "'A' is defined in terms of itself: A -> A", 1},
+		{"redefined", "def A = PathExists \"/\"\ndef A = PathExists \"/etc\"", aeaconf2.CodeDuplicateDefinition, "'A' is already defined", 2},
+		{"keyword", "def all = ServiceUp \"x\"", aeaconf2.CodeSyntax, "'all' is a keyword, so can't be defined with 'def'", 1},
+		{"function name", "def ServiceUpNot = PathExists \"/\"", aeaconf2.CodeSyntax, "'ServiceUpNot' is already a function (the negation of 'ServiceUp')", 1},
+		{"arguments", "def A = PathExists \"/\"\n\"a\": 3; A \"/etc\"", aeaconf2.CodeArgumentCount, "'A' is a definition and takes no arguments, found string `\"/etc\"`", 2},
+		{"error in unused body", "def A = PathExists 3\n\"a\": 3; ServiceUp \"sshd\"", aeaconf2.CodeArgumentType, "expected a string for argument 'Path' of 'PathExists', found number `3`", 1},
+		{"empty", "def A =\n\"a\": 3; ServiceUp \"sshd\"", aeaconf2.CodeSyntax, "expected a condition for definition 'A'", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := buildChecks(tt.source)
+			var pe *aeaconf2.ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("expected *ParseError, got %v", err)
+			}
+			if pe.Code != tt.code || pe.Message != tt.message || pe.Line != tt.line {
+				t.Errorf("expected %s %q on line %d, got %s %q on line %d", tt.code, tt.message, tt.line, pe.Code, pe.Message, pe.Line)
+			}
+		})
+	}
+
+	// errors in a body also point at where it was used
+	_, err := buildChecks("def A = PathExists 3\n\"a\": 3; A")
+	var pe *aeaconf2.ParseError
+	errors.As(err, &pe)
+	if len(pe.Notes) == 0 {
+		t.Fatal("expected a note")
+	}
+	if note := pe.Notes[len(pe.Notes)-1]; note.Message != "in 'A', used here" || note.Span.Start.Line != 2 {
+		t.Errorf("unexpected note: %+v", note)
+	}
+}
@@ -1,42 +1,163 @@
 package aeaconf2
 
 import (
+	"bytes"
 	"fmt"
+	"io/fs"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
 )
 
 type Parser struct {
 	Lexer          *Lexer
 	Lookahead      *Token
 	LookaheadValid bool
+	// tokens read past the lookahead, in order; see peekAt
+	pending []*Token
+	// most recently consumed token
+	Previous *Token
+	// indentation of the line being parsed
+	lineIndent string
 	// currently-parsing check message; used for debugging
 	CurrentCheckMessage string
+	// header of the currently-parsing check; used for diagnostic notes
+	CurrentCheckSpan Span
 
 	// map from function names to corresponding reflect type
 	FuncRegistry map[string]reflect.Type
+	// `let` definitions seen so far, by name
+	Constants map[string]*Constant
+	// `def` definitions seen so far, by name
+	Macros map[string]*Macro
+	// token streams read in place of the lexer's, innermost last
+	replays []*replay
+	// macros being expanded, innermost last
+	expanding []*Macro
+	// `template` definitions seen so far, by name
+	Templates map[string]*Template
+	// values of template parameters, innermost last
+	scopes []map[string]string
+
+	// file system included files are read from; nil for the OS's
+	FS fs.FS
+	// every file read so far
+	sources *sourceSet
+	// the file being parsed, preceded by those including it
+	includeStack []string
+	// values `@if` directives test, e.g. the config's [round] section
+	Header map[string]string
+	// `@if` sections being parsed, innermost last
+	conditionals []*conditional
+
+	// every lexer and parser error recorded so far, in source order (with
+	// errors in unused definitions found last)
+	Errors ParseErrors
+	// non-fatal diagnostics found while parsing; see Lint
+	Warnings []*Diagnostic
 }
 
 func NewParser(lexer *Lexer, funcRegistry map[string]reflect.Type) *Parser {
-	return &Parser{Lexer: lexer, Lookahead: nil, LookaheadValid: false, FuncRegistry: funcRegistry}
+	sources := newSourceSet()
+	sources.add(lexer)
+	return &Parser{
+		Lexer:          lexer,
+		Lookahead:      nil,
+		LookaheadValid: false,
+		FuncRegistry:   funcRegistry,
+		Constants:      make(map[string]*Constant),
+		Macros:         make(map[string]*Macro),
+		Templates:      make(map[string]*Template),
+		sources:        sources,
+		includeStack:   []string{lexer.File.Name},
+	}
 }
 
+// Errorf reports an error at the token being looked at
 func (p *Parser) Errorf(format string, a ...any) {
-	message := fmt.Sprintf(format, a...)
-	line, column := p.Lexer.GetSourceVisualLocation()
-	Fatal(STAGE_PARSER, fmt.Sprintf("(line %d, column %d) %s", line, column, message))
+	p.ErrorAt(p.currentSpan(), format, a...)
+}
+
+func (p *Parser) ErrorAt(span Span, format string, a ...any) {
+	p.ErrorWithNotes(CodeSyntax, span, nil, format, a...)
+}
+
+func (p *Parser) ErrorWithNotes(code string, span Span, notes []Note, format string, a ...any) {
+	pe := NewParseError(STAGE_PARSER, code, p.Lexer.File, span, fmt.Sprintf(format, a...))
+	pe.Notes = notes
+	panic(pe)
+}
+
+// Warn records a non-fatal diagnostic, reported alongside those from Lint
+func (p *Parser) Warn(code string, span Span, format string, a ...any) {
+	p.Warnings = append(p.Warnings, &Diagnostic{
+		Severity: SeverityWarning,
+		Stage:    STAGE_LINT,
+		Code:     code,
+		Message:  fmt.Sprintf(format, a...),
+		Span:     span,
+		source:   p.Lexer.File,
+		files:    p.sources.files,
+	})
+}
+
+// current token for errors: the lookahead if one has been peeked,
+// otherwise the token just consumed
+func (p *Parser) currentSpan() Span {
+	if p.LookaheadValid {
+		return p.Lookahead.Span
+	} else if p.Previous != nil {
+		return p.Previous.Span
+	}
+	return p.Lexer.File.Span(p.Lexer.Pos, p.Lexer.Pos)
 }
 
 func (p *Parser) Peek() *Token {
 	if !p.LookaheadValid {
-		p.Lookahead = p.Lexer.NextToken()
+		if len(p.pending) != 0 {
+			p.Lookahead = p.pending[0]
+			p.pending = p.pending[1:]
+		} else {
+			p.Lookahead = p.fetch()
+		}
 		p.LookaheadValid = true
 	}
 	return p.Lookahead
 }
 
+// peekAt returns the token n tokens past the lookahead (which is n = 0)
+// without consuming anything
+func (p *Parser) peekAt(n int) *Token {
+	if n == 0 {
+		return p.Peek()
+	}
+	p.Peek()
+	for len(p.pending) < n {
+		p.pending = append(p.pending, p.fetch())
+	}
+	return p.pending[n-1]
+}
+
+// fetch reads the next token from the innermost replay, or the lexer
+func (p *Parser) fetch() *Token {
+	if n := len(p.replays); n != 0 {
+		return p.replays[n-1].next()
+	}
+	return p.Lexer.NextToken()
+}
+
 func (p *Parser) Consume() *Token {
 	token := p.Peek()
 	p.LookaheadValid = false
+	p.Previous = token
+	switch token.Type {
+	case NewTokenline:
+		p.lineIndent = ""
+	case TokenIndent:
+		p.lineIndent = string(token.Lexeme)
+	}
 	return token
 }
 
@@ -55,8 +176,6 @@ func (p *Parser) SkipUntilNewlineBlock() {
 			if token.Type != NewTokenline {
 				p.Errorf("expected non-indented line to begin new check")
 			}
-		} else if token.Type == TokenEOF {
-			p.Errorf("unexpected EOF: expected non-indented line to begin new check")
 		} else {
 			return
 		}
@@ -78,7 +197,8 @@ func (p *Parser) SkipUntilIndentedBlock() bool {
 				return false
 			}
 		} else if token.Type == TokenEOF {
-			p.Errorf("unexpected EOF: expected indented line to begin new condition")
+			// the last check in the file; NextCheck reports empty blocks
+			return false
 		} else {
 			return true
 		}
@@ -100,13 +220,23 @@ func (p *Parser) SkipUntilIndentedBlockIfHanging() {
 func (p *Parser) ExpectTokenType(tokenType TokenType, msg string) *Token {
 	nextToken := p.Consume()
 	if nextToken.Type != tokenType {
-		p.Errorf("expected token of type '%s', got '%s' (type '%s'): %s",
-			tokenType.Str(), nextToken.Value(), nextToken.Type.Str(), msg)
+		p.Errorf("expected %s, found %s: %s", tokenType.Describe(), nextToken.Describe(), msg)
 		return nil // unreachable
 	}
 	return nextToken
 }
 
+// ExpectInt consumes a number token, as ExpectTokenType, and returns its
+// value, reporting numbers too large for an int
+func (p *Parser) ExpectInt(msg string) int {
+	token := p.ExpectTokenType(TokenNumber, msg)
+	value, err := strconv.Atoi(string(token.Lexeme))
+	if err != nil {
+		p.ErrorAt(token.Span, "number %s is out of range", token.Lexeme)
+	}
+	return value
+}
+
 func (p *Parser) MaybeParseHint() string {
 	if p.Peek().Type == TokenLBracket {
 		// assume LBracket ([) has been peeked
@@ -119,23 +249,72 @@ func (p *Parser) MaybeParseHint() string {
 			TokenRBracket,
 			fmt.Sprintf("expecting closing right-brace (]) for hint: %s", hintString.Value()),
 		)
-		return hintString.Value().(string)
+
+		hint := p.Interpolate(hintString)
+		if strings.TrimSpace(hint) == "" {
+			p.Warn(LintEmptyHint, hintString.Span, "empty hint has no effect")
+		}
+		return hint
 	}
 	return ""
 }
 
+// ParseCondition parses a boolean expression. From loosest to tightest,
+// operators are `=>` (right-associative), `||`, `^^` and `&&`.
 func (p *Parser) ParseCondition() Condition {
-	lhs := p.ParseAnd()
+	lhs := p.ParseOr()
+	if p.Peek().Type == TokenImplies {
+		p.Consume()
+		p.SkipUntilIndentedBlockIfHanging()
+
+		rhs := p.ParseCondition()
+		implies := &ImpliesExpr{Lhs: lhs, Rhs: rhs}
+		implies.Span = ConditionSpan(lhs).Join(ConditionSpan(rhs))
+		return implies
+	}
+	return lhs
+}
+
+func (p *Parser) ParseOr() Condition {
+	lhs := p.ParseXor()
 	for p.Peek().Type == TokenOr {
 		p.Consume()
 		p.SkipUntilIndentedBlockIfHanging()
 
-		rhs := p.ParseAnd()
-		lhs = &OrExpr{Lhs: lhs, Rhs: rhs}
+		rhs := p.ParseXor()
+		or := &OrExpr{Lhs: lhs, Rhs: rhs}
+		or.Span = ConditionSpan(lhs).Join(ConditionSpan(rhs))
+		lhs = or
 	}
 	return lhs
 }
 
+// ParseXor parses `A ^^ B`, which holds when exactly one operand holds.
+// Chains hold when exactly one of all their operands does (not when an odd
+// number do), so are parsed as `exactly 1 (A, B, C)`.
+func (p *Parser) ParseXor() Condition {
+	operands := []Condition{p.ParseAnd()}
+	for p.Peek().Type == TokenXor {
+		p.Consume()
+		p.SkipUntilIndentedBlockIfHanging()
+		operands = append(operands, p.ParseAnd())
+	}
+
+	first, last := operands[0], operands[len(operands)-1]
+	switch len(operands) {
+	case 1:
+		return first
+	case 2:
+		xor := &XorExpr{Lhs: first, Rhs: last}
+		xor.Span = ConditionSpan(first).Join(ConditionSpan(last))
+		return xor
+	default:
+		exactly := &ThresholdExpr{Kind: Exactly, Count: 1, Conditions: operands}
+		exactly.Span = ConditionSpan(first).Join(ConditionSpan(last))
+		return exactly
+	}
+}
+
 func (p *Parser) ParseAnd() Condition {
 	lhs := p.ParseFactor()
 	for p.Peek().Type == TokenAnd {
@@ -143,88 +322,272 @@ func (p *Parser) ParseAnd() Condition {
 		p.SkipUntilIndentedBlockIfHanging()
 
 		rhs := p.ParseFactor()
-		lhs = &AndExpr{Lhs: lhs, Rhs: rhs}
+		and := &AndExpr{Lhs: lhs, Rhs: rhs}
+		and.Span = ConditionSpan(lhs).Join(ConditionSpan(rhs))
+		lhs = and
 	}
 	return lhs
 }
 
+// ParseFactor parses a (possibly negated) function call or parenthesized
+// expression, followed by an optional hint
 func (p *Parser) ParseFactor() Condition {
+	cond := p.ParseUnary()
+
+	hint := p.MaybeParseHint()
+	if len(hint) != 0 {
+		SetConditionHint(cond, hint)
+	}
+	return cond
+}
+
+func isKeyword(token *Token, keyword string) bool {
+	return token.Type == TokenIdent && string(token.Lexeme) == keyword
+}
+
+func (p *Parser) ParseUnary() Condition {
 	next := p.Peek()
 
 	var cond Condition
-	if next.Type == TokenLParen {
+	if next.Type == TokenNot || isKeyword(next, "not") {
+		p.Consume()
+		operand := p.ParseUnary()
+		not := &NotFunc{Func: operand}
+		not.Span = next.Span.Join(ConditionSpan(operand))
+		cond = not
+	} else if next.Type == TokenLParen {
 		p.Consume()
 		cond = p.ParseCondition()
-		p.ExpectTokenType(
-			TokenRParen,
-			fmt.Sprintf("expected closing right parenthese for condition for check: '%s'", p.CurrentCheckMessage),
-		)
+		if p.Peek().Type != TokenRParen {
+			p.ErrorWithNotes(
+				CodeUnclosedParen,
+				p.Peek().Span,
+				[]Note{
+					{Message: "parenthesized expression opened here", Span: next.Span},
+					{Message: "check started here", Span: p.CurrentCheckSpan},
+				},
+				"expected ')' to close parenthesized expression for check '%s', found %s",
+				p.CurrentCheckMessage,
+				p.Peek().Describe(),
+			)
+		}
+		rParen := p.Consume()
+		// point at the whole parenthesized group
+		SetConditionSpan(cond, next.Span.Join(rParen.Span))
+	} else if p.isGroupStart() {
+		cond = p.ParseGroup()
+	} else if kind, ok := thresholdKinds[string(next.Lexeme)]; ok && next.Type == TokenIdent {
+		cond = p.ParseThreshold(kind)
 	} else if next.Type == TokenIdent {
 		cond = p.ParseFunc()
 	} else {
 		p.Errorf(
-			"invalid boolean expression for check '%s': expected function or parenthesized expression, got '%s'",
+			"invalid boolean expression for check '%s': expected function, negation or parenthesized expression, found %s",
 			p.CurrentCheckMessage,
-			next.Debug(),
+			next.Describe(),
 		)
 	}
+	return cond
+}
 
-	hint := p.MaybeParseHint()
-	if len(hint) != 0 {
-		SetConditionHint(cond, hint)
+var thresholdKinds = map[string]ThresholdKind{
+	AtLeast.Keyword(): AtLeast,
+	Exactly.Keyword(): Exactly,
+	AtMost.Keyword():  AtMost,
+}
+
+// ParseThreshold parses e.g. `atleast 2 (A, B, C)`, whose operands may
+// span lines
+func (p *Parser) ParseThreshold(kind ThresholdKind) Condition {
+	keyword := p.Consume()
+	count := p.ExpectInt(fmt.Sprintf("expected the number of conditions following '%s'", keyword.Lexeme))
+	if count < 0 {
+		p.ErrorAt(p.Previous.Span, "expected a non-negative number of conditions following '%s', found %d", keyword.Lexeme, count)
 	}
-	return cond
+	open := p.ExpectTokenType(TokenLParen, fmt.Sprintf("expected '(' to open the conditions of '%s %d'", keyword.Lexeme, count))
+
+	threshold := &ThresholdExpr{Kind: kind, Count: count}
+	for {
+		p.skipLineBreaks()
+		if p.Peek().Type == TokenRParen {
+			break
+		}
+		threshold.Conditions = append(threshold.Conditions, p.ParseCondition())
+
+		p.skipLineBreaks()
+		if p.Peek().Type != TokenComma {
+			break
+		}
+		p.Consume()
+	}
+
+	if p.Peek().Type != TokenRParen {
+		p.ErrorWithNotes(
+			CodeUnclosedParen,
+			p.currentSpan(),
+			[]Note{{Message: "conditions opened here", Span: open.Span}},
+			"expected ',' or ')' in the conditions of '%s %d', found %s",
+			keyword.Lexeme,
+			count,
+			p.Peek().Describe(),
+		)
+	}
+	rParen := p.Consume()
+	if len(threshold.Conditions) == 0 {
+		p.ErrorAt(open.Span.Join(rParen.Span), "expected at least one condition for '%s %d'", keyword.Lexeme, count)
+	}
+
+	threshold.Span = keyword.Span.Join(rParen.Span)
+	return threshold
 }
 
 func (p *Parser) ParseFunc() Condition {
-	funcName := p.Consume().Value().(string)
-	if len(funcName) <= len("Not") {
-		p.Errorf("invalid check name: %s", funcName)
-	}
+	nameToken := p.Consume()
+	funcName := nameToken.Value().(string)
 
+	// registered names win over stripping the 'Not' suffix
 	notFunc := false
-	notIdx := len(funcName) - len("Not")
-	if funcName[notIdx:] == "Not" {
+	funcType, ok := p.FuncRegistry[funcName]
+	_, isMacro := p.Macros[funcName]
+	if !ok && !isMacro && len(funcName) > len("Not") && strings.HasSuffix(funcName, "Not") {
 		notFunc = true
-		funcName = funcName[:notIdx]
+		funcName = strings.TrimSuffix(funcName, "Not")
+		funcType, ok = p.FuncRegistry[funcName]
+	}
+	if macro, isMacro := p.Macros[funcName]; isMacro {
+		fun := p.ExpandMacro(macro, nameToken.Span)
+		if extra := p.Peek(); isArgumentStart(extra) {
+			p.ErrorWithNotes(CodeArgumentCount, extra.Span, []Note{{Message: "defined here", Span: macro.Span}},
+				"'%s' is a definition and takes no arguments, found %s", funcName, extra.Describe())
+		}
+		if notFunc {
+			not := &NotFunc{Func: fun}
+			not.Span = nameToken.Span
+			return not
+		}
+		return fun
 	}
-
-	funcType, ok := p.FuncRegistry[funcName]
 	if !ok {
-		p.Errorf("invalid function name: %s", funcName)
+		name := nameToken.Value().(string)
+		var notes []Note
+		if suggestions := SuggestFunctions(name, p.FuncRegistry); len(suggestions) != 0 {
+			notes = append(notes, Note{Message: "did you mean " + formatSuggestions(suggestions) + "?"})
+		}
+		p.ErrorWithNotes(CodeUnknownFunction, nameToken.Span, notes, "unknown function `%s`", name)
 	}
-	numArgs := funcType.NumField()
+	args := FunctionArguments(funcType)
+	numArgs := len(args)
+	signature := []Note{{Message: "expected signature: " + FunctionSignature(funcName, funcType)}}
 
 	ptr := reflect.New(funcType)
 	elem := ptr.Elem()
 
-	// Skip "BaseCondition" field
-	for i := 1; i < numArgs; i++ {
-		field := funcType.Field(i)
-		arg := p.ExpectTokenType(
-			TokenString,
-			fmt.Sprintf(
-				"expected argument for condition '%s': '%s' (arg %d out of %d total arguments)",
-				funcName,
-				field.Name,
-				i,
-				numArgs,
-			),
-		).Value().(string)
+	// the token each argument was given by, positionally or by name
+	given := make([]*Token, numArgs)
+	numGiven := 0
+	positional := 0
+	var firstNamed *Token
+
+	for isArgumentStart(p.Peek()) {
+		token := p.Consume()
+
+		if token.Type == TokenIdent && p.Peek().Type == TokenEquals {
+			p.Consume()
+			i := p.lookupNamedArgument(funcName, token, args, signature)
+			if given[i] != nil {
+				p.ErrorWithNotes(CodeDuplicateArgument, token.Span,
+					append([]Note{{Message: "first given here", Span: given[i].Span}}, signature...),
+					"argument '%s' of '%s' is given more than once", args[i].Name, funcName)
+			}
+			if !isArgumentStart(p.Peek()) {
+				p.ErrorWithNotes(CodeSyntax, p.currentSpan(), signature, "expected a value for argument '%s' of '%s', found %s",
+					args[i].Name, funcName, p.Peek().Describe())
+			}
+			given[i] = token
+			numGiven++
+			if firstNamed == nil {
+				firstNamed = token
+			}
+			p.BindArgument(funcName, args[i], p.Consume(), elem.Field(args[i].Index), signature)
+			continue
+		}
+
+		if firstNamed != nil {
+			p.ErrorWithNotes(CodeSyntax, token.Span, []Note{{Message: "named argument given here", Span: firstNamed.Span}},
+				"positional arguments to '%s' must come before named arguments", funcName)
+		}
+		if positional == numArgs {
+			p.ErrorWithNotes(CodeArgumentCount, token.Span, signature, "too many arguments for '%s': expected %s, found extra %s",
+				funcName, describeArgumentCount(args), token.Describe())
+		}
+		if given[positional] == nil {
+			given[positional] = token
+			numGiven++
+		}
+		p.BindArgument(funcName, args[positional], token, elem.Field(args[positional].Index), signature)
+		// a trailing list also takes the arguments repeated after it
+		if !args[positional].IsList() || positional != numArgs-1 || token.Type == TokenLBrace {
+			positional++
+		}
+	}
 
-		elem.Field(i).SetString(arg)
+	for i, arg := range args {
+		if given[i] != nil {
+			continue
+		}
+		if !arg.Optional {
+			p.ErrorWithNotes(CodeArgumentCount, nameToken.Span.Join(p.Previous.Span), signature,
+				"missing argument '%s' for '%s': expected %s, found %d",
+				arg.Name, funcName, describeArgumentCount(args), numGiven)
+		}
+		if arg.HasDefault {
+			value, err := parseDefault(arg)
+			if err != nil {
+				// only for registries that weren't checked with CheckFunctionRegistry
+				p.ErrorWithNotes(CodeArgumentType, nameToken.Span, signature,
+					"invalid default for argument '%s' of '%s': %s", arg.Name, funcName, err)
+			}
+			elem.Field(arg.Index).Set(value)
+		}
 	}
 
 	fun := ptr.Interface().(Condition)
+	span := nameToken.Span.Join(p.Previous.Span)
+	SetConditionSpan(fun, span)
 
 	if notFunc {
-		return &NotFunc{Func: fun}
+		not := &NotFunc{Func: fun}
+		not.Span = span
+		return not
 	}
 	return fun
 }
 
+// lookupNamedArgument finds the argument `name=` refers to
+func (p *Parser) lookupNamedArgument(funcName string, name *Token, args []Argument, signature []Note) int {
+	for i, arg := range args {
+		if arg.matches(string(name.Lexeme)) {
+			return i
+		}
+	}
+
+	var names []string
+	for _, arg := range args {
+		names = append(names, arg.Name)
+	}
+	sort.Strings(names)
+	notes := signature
+	if suggestions := suggest(string(name.Lexeme), names); len(suggestions) != 0 {
+		notes = append([]Note{{Message: "did you mean " + formatSuggestions(suggestions) + "?"}}, signature...)
+	}
+	p.ErrorWithNotes(CodeUnknownArgument, name.Span, notes, "'%s' has no argument named '%s'", funcName, name.Lexeme)
+	return -1
+}
+
 func (p *Parser) NextCheck() *Check {
 	p.SkipUntilNewlineBlock()
+	headerStart := p.Peek().Span
+	p.CurrentCheckSpan = headerStart
 
 	// current check has empty message; avoids any "magic" generation-needing message
 	currentCheckMessageEmpty := false
@@ -235,10 +598,10 @@ func (p *Parser) NextCheck() *Check {
 		currentCheckMessageEmpty = true
 		p.Consume()
 	} else {
-		p.CurrentCheckMessage = p.ExpectTokenType(
+		p.CurrentCheckMessage = p.Interpolate(p.ExpectTokenType(
 			TokenString,
 			"expected check title as a string or placeholder ('_')",
-		).Value().(string)
+		))
 	}
 
 	p.ExpectTokenType(
@@ -254,16 +617,22 @@ func (p *Parser) NextCheck() *Check {
 		pointsEmpty = true
 		p.Consume()
 	} else {
-		points = p.ExpectTokenType(
-			TokenNumber,
+		points = p.ExpectInt(
 			fmt.Sprintf("expected integer point value or placeholder ('_') to follow colon for check: '%s'",
 				p.CurrentCheckMessage),
-		).Value().(int)
+		)
 	}
 
 	// parse hint if it exists
 	rootHint := p.MaybeParseHint()
 
+	// parse precondition if it exists
+	var precondition Condition
+	if isKeyword(p.Peek(), "when") {
+		p.Consume()
+		precondition = p.ParseCondition()
+	}
+
 	var finalCond Condition
 	// if single-line check
 	if p.Peek().Type == TokenSemicolon {
@@ -278,9 +647,13 @@ func (p *Parser) NextCheck() *Check {
 
 		// no conditions parsed
 		if len(andedConditions) == 0 {
-			p.Errorf(
-				"unexpected end of file: expected an indented condition block for check '%s'",
+			p.ErrorWithNotes(
+				CodeEmptyCheck,
+				p.currentSpan(),
+				[]Note{{Message: "check started here", Span: headerStart}},
+				"expected an indented condition block for check '%s', found %s",
 				p.CurrentCheckMessage,
+				p.Peek().Describe(),
 			)
 		}
 
@@ -292,14 +665,173 @@ func (p *Parser) NextCheck() *Check {
 		checkString = finalCond.DefaultString()
 	}
 
-	return &Check{Message: checkString, Points: points, PointsEmpty: pointsEmpty, Condition: finalCond, Hint: rootHint}
+	return &Check{
+		Message:      checkString,
+		MessageEmpty: currentCheckMessageEmpty,
+		Points:       points,
+		PointsEmpty:  pointsEmpty,
+		Condition:    finalCond,
+		Precondition: precondition,
+		Hint:         rootHint,
+		Span:         headerStart.Join(ConditionSpan(finalCond)),
+	}
 }
 
+// Synchronize discards the remainder of a broken check by moving the lexer
+// to the next non-indented line after byte offset `from`. This is what
+// SkipUntilNewlineBlock looks for, but done on raw bytes so that whatever
+// made the lexer fail isn't lexed (and reported) again. Strings and
+// comments are skipped whole, as lines within them don't start checks.
+func (p *Parser) Synchronize(from int) {
+	pos := p.Lexer.Pos
+	if p.LookaheadValid {
+		pos = p.Lookahead.Span.Start.Offset
+	} else if len(p.pending) != 0 {
+		pos = p.pending[0].Span.Start.Offset
+	}
+	p.LookaheadValid = false
+	p.pending = nil
+	p.lineIndent = ""
+
+	source := p.Lexer.Source
+	for pos < len(source) {
+		lineStart := pos == 0 || source[pos-1] == '\n'
+		if pos > from && lineStart && !unicode.IsSpace(rune(source[pos])) {
+			break
+		}
+		if end := literalEnd(source, pos); end > pos {
+			pos = end
+			continue
+		}
+		pos++
+	}
+	p.Lexer.Pos = pos
+}
+
+// literalEnd returns the offset just past the string or comment starting at
+// pos, found as the lexer would, or pos if none does (or it's unterminated)
+func literalEnd(source []byte, pos int) int {
+	rest := source[pos:]
+	var open, close []byte
+	switch {
+	case bytes.HasPrefix(rest, []byte("//")):
+		if end := bytes.IndexByte(rest, '\n'); end >= 0 {
+			return pos + end
+		}
+		return len(source)
+	case bytes.HasPrefix(rest, []byte(`"""`)):
+		open, close = []byte(`"""`), []byte(`"""`)
+	case rest[0] == '"', rest[0] == '\'':
+		open, close = rest[:1], rest[:1]
+	case rest[0] == '`':
+		// raw strings have no escapes
+		if end := bytes.IndexByte(rest[1:], '`'); end >= 0 {
+			return pos + 1 + end + 1
+		}
+		return pos
+	default:
+		return pos
+	}
+
+	for i := len(open); i < len(rest); i++ {
+		if bytes.HasPrefix(rest[i:], close) {
+			return pos + i + len(close)
+		}
+		if rest[i] == '\\' {
+			i++
+		}
+	}
+	return pos
+}
+
+// recoverNextChecks parses the next check (or the checks generated by the
+// next top-level construct), recording any error and resynchronizing at the
+// following check instead of unwinding
+func (p *Parser) recoverNextChecks() (checks []*Check, eof bool) {
+	from := p.Lexer.Pos
+	if p.LookaheadValid {
+		from = p.Lookahead.Span.Start.Offset
+	} else if len(p.pending) != 0 {
+		from = p.pending[0].Span.Start.Offset
+	}
+
+	pe := catchParseError(func() {
+		p.SkipUntilNewlineBlock()
+		if p.Peek().Type == TokenEOF {
+			p.checkConditionalsClosed()
+			eof = true
+			// definitions may be used by any file, so are checked once all are read
+			if len(p.includeStack) == 1 {
+				p.checkUnusedConstants()
+				p.checkUnusedMacros()
+				p.checkUnusedTemplates()
+			}
+			return
+		}
+		if p.Peek().Type == TokenDirective {
+			p.ParseDirective()
+			return
+		}
+		if !p.sectionActive() {
+			p.captureBlock(p.Peek().Span)
+			return
+		}
+		if isKeyword(p.Peek(), "include") {
+			checks = p.ParseInclude()
+			return
+		}
+		if p.ParseDefinition() {
+			return
+		}
+		if isKeyword(p.Peek(), "for") {
+			checks = p.ParseFor()
+			return
+		}
+		if next := p.Peek(); next.Type == TokenIdent && p.Templates[string(next.Lexeme)] != nil {
+			checks = []*Check{p.InstantiateTemplate()}
+			return
+		}
+		checks = []*Check{p.NextCheck()}
+	})
+	if pe != nil {
+		pe.files = p.sources.files
+		p.Errors = append(p.Errors, pe)
+		if len(p.replays) != 0 {
+			// the error was in a definition; resume after the check using it
+			p.resetReplays()
+		}
+		if pe.Stage == STAGE_LEXER {
+			// never resume on the character the lexer choked on
+			from = max(from, p.Lexer.Pos)
+		}
+		p.Synchronize(from)
+		return nil, false
+	}
+	return checks, eof
+}
+
+// Parse is like Checks, but fails with every recorded error
+func (p *Parser) Parse() ([]*Check, error) {
+	checks := p.Checks()
+	if len(p.Errors) != 0 {
+		return nil, p.Errors
+	}
+	return checks, nil
+}
+
+// Checks parses every check in the source. Broken checks are left out and
+// their errors recorded in p.Errors.
 func (p *Parser) Checks() []*Check {
 	var checks []*Check
-	for p.Peek().Type != TokenEOF {
-		checks = append(checks, p.NextCheck())
-		p.SkipUntilNewlineBlock()
+	for {
+		next, eof := p.recoverNextChecks()
+		if eof {
+			// unused definitions are checked last
+			sort.SliceStable(p.Errors, func(i, j int) bool {
+				return p.sources.before(p.Errors[i].Span, p.Errors[j].Span)
+			})
+			return checks
+		}
+		checks = append(checks, next...)
 	}
-	return checks
 }
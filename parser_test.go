@@ -0,0 +1,581 @@
+package aeaconf2_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/safinsingh/aeaconf2"
+)
+
+func buildChecks(source string) ([]*aeaconf2.Check, error) {
+	return aeaconf2.DefaultAeaconfBuilder([]byte(source), getFunctionRegistry()).Build()
+}
+
+func TestBuildReturnsParseError(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		stage  aeaconf2.CompilerStage
+		line   int
+	}{
+		{"unknown function", "\"a\": 3; ServiceUpp \"sshd\"", aeaconf2.STAGE_PARSER, 1},
+		{"missing colon", "\"a\": 3; ServiceUp \"sshd\"\n\"b\" 3; ServiceUp \"sshd\"", aeaconf2.STAGE_PARSER, 2},
+		{"unterminated string", "\"a\": 3; ServiceUp \"sshd", aeaconf2.STAGE_LEXER, 1},
+		{"leading blank lines", "\n\n\"a\": 3; ServiceUpp \"sshd\"\n\n", aeaconf2.STAGE_PARSER, 3},
+		{"point overflow", "\"a\": 100; ServiceUp \"sshd\"\n\"b\": _; ServiceUp \"sshd\"", aeaconf2.STAGE_DISTRIBUTION, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := buildChecks(tt.source)
+			var pe *aeaconf2.ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("expected *ParseError, got %v", err)
+			}
+			if pe.Stage != tt.stage || pe.Line != tt.line {
+				t.Errorf("got stage %s line %d, want stage %s line %d: %s", pe.Stage, pe.Line, tt.stage, tt.line, pe)
+			}
+		})
+	}
+}
+
+func TestBuildRecoversAfterErrors(t *testing.T) {
+	source := `"a": 3
+	ServiceUpp "sshd"
+"b": 2; ServiceUp "sshd"
+$"c": 1; ServiceUp "sshd"
+"d": 4
+	ServiceUp "sshd" ||
+"e" 1; ServiceUp "sshd"
+"f": 5; PathExists "/etc"`
+
+	_, err := buildChecks(source)
+	var errs aeaconf2.ParseErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ParseErrors, got %v", err)
+	}
+
+	wantLines := []int{2, 4, 7, 7}
+	if len(errs) != len(wantLines) {
+		t.Fatalf("expected %d errors, got %d:\n%s", len(wantLines), len(errs), errs)
+	}
+	for i, pe := range errs {
+		if pe.Line != wantLines[i] {
+			t.Errorf("error %d: expected line %d, got %s", i, wantLines[i], pe)
+		}
+	}
+}
+
+func TestChecksKeepsValidChecks(t *testing.T) {
+	source := "\"a\": 3; ServiceUpp \"sshd\"\n\"b\": 2; ServiceUp \"sshd\"\n$\n\"c\": 1; ServiceUp \"sshd\""
+	p := aeaconf2.NewParser(aeaconf2.NewLexer([]byte(source), 0), getFunctionRegistry())
+	checks := p.Checks()
+
+	if len(checks) != 2 || checks[0].Message != "b" || checks[1].Message != "c" {
+		t.Errorf("expected checks 'b' and 'c' to survive, got %d checks", len(checks))
+	}
+	if len(p.Errors) != 2 {
+		t.Errorf("expected 2 errors, got %d:\n%s", len(p.Errors), p.Errors)
+	}
+}
+
+func TestRecoverySkipsStrings(t *testing.T) {
+	sources := []string{
+		"\"a\": 1; ServiceUpp \"x\" \"\"\"\nFoo: bar\n\"\"\"\n\"b\": 1; PathExists \"y\"",
+		"\"a\": 1; ServiceUpp \"x\" `\nFoo: bar\n`\n\"b\": 1; PathExists \"y\"",
+		"\"a\": 1; ServiceUp \"\\q\nFoo: bar\n\"\n\"b\": 1; PathExists \"y\"",
+	}
+
+	for _, source := range sources {
+		p := aeaconf2.NewParser(aeaconf2.NewLexer([]byte(source), 0), getFunctionRegistry())
+		checks := p.Checks()
+		if len(p.Errors) != 1 {
+			t.Errorf("%q: expected 1 error, got %d:\n%s", source, len(p.Errors), p.Errors)
+		}
+		if len(checks) != 1 || checks[0].Message != "b" {
+			t.Errorf("%q: expected check 'b' to survive, got %d checks", source, len(checks))
+		}
+	}
+}
+
+func TestSpans(t *testing.T) {
+	source := "\"a\": 3\n\tServiceUp \"sshd\" && (PathExists \"/etc\" || PathExistsNot \"/var\")\n"
+	checks, err := aeaconf2.DefaultAeaconfBuilder([]byte(source), getFunctionRegistry()).
+		SetFileName("checks.acf").
+		SetLineOffset(10).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := checks[0]
+	if check.Span.Start.Line != 11 || check.Span.End.Line != 12 || check.Span.Start.File != "checks.acf" {
+		t.Errorf("unexpected check span: %+v", check.Span)
+	}
+
+	and := check.Condition.(*aeaconf2.AndExpr)
+	wantSpans := []struct {
+		cond         aeaconf2.Condition
+		start, end   int
+		line, column int
+	}{
+		{and, 8, 71, 12, 2},
+		{and.Lhs, 8, 24, 12, 2},
+		{and.Rhs, 28, 71, 12, 22},
+		{and.Rhs.(*aeaconf2.OrExpr).Rhs, 50, 70, 12, 44},
+	}
+	for _, want := range wantSpans {
+		span := aeaconf2.ConditionSpan(want.cond)
+		if span.Start.Offset != want.start || span.End.Offset != want.end ||
+			span.Start.Line != want.line || span.Start.Column != want.column {
+			t.Errorf("%s: unexpected span %+v", want.cond.DefaultString(), span)
+		}
+	}
+}
+
+func TestRenderDiagnostic(t *testing.T) {
+	color.NoColor = true
+	source := "\"a\": 3\n\t(ServiceUp \"x\" ||\n\t\tPathExists \"y\""
+	_, err := aeaconf2.DefaultAeaconfBuilder([]byte(source), getFunctionRegistry()).
+		SetFileName("checks.acf").
+		SetLineOffset(8).
+		Build()
+
+	var pe *aeaconf2.ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected *ParseError, got %v", err)
+	}
+
+	want := `error[unclosed-paren]: expected ')' to close parenthesized expression for check 'a', found end of file
+  --> checks.acf:11:17
+   |
+11 | 		PathExists "y"
+   | 		              ^
+note: parenthesized expression opened here
+  --> checks.acf:10:2
+   |
+10 | 	(ServiceUp "x" ||
+   | 	^
+note: check started here
+  --> checks.acf:9:1
+   |
+ 9 | "a": 3
+   | ^^^
+`
+	if got := pe.Render(); got != want {
+		t.Errorf("unexpected rendering:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFunctionSuggestions(t *testing.T) {
+	registry := getFunctionRegistry()
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"ServiceUpp", []string{"ServiceUp"}},
+		{"serviceupnot", []string{"ServiceUpNot"}},
+		{"PathExistNot", []string{"PathExistsNot"}},
+		{"Frobnicate", nil},
+	}
+
+	for _, tt := range tests {
+		got := aeaconf2.SuggestFunctions(tt.name, registry)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: expected suggestions %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestArgumentCountErrors(t *testing.T) {
+	tests := []struct {
+		source  string
+		message string
+	}{
+		{"\"a\": 3; FileContains \"/etc/passwd\"", "missing argument 'Value' for 'FileContains': expected 2 arguments, found 1"},
+		{"\"a\": 3; ServiceUp \"sshd\" \"ftp\"", "too many arguments for 'ServiceUp': expected 1 argument, found extra string `\"ftp\"`"},
+	}
+
+	for _, tt := range tests {
+		_, err := buildChecks(tt.source)
+		var pe *aeaconf2.ParseError
+		if !errors.As(err, &pe) {
+			t.Fatalf("expected *ParseError, got %v", err)
+		}
+		if pe.Message != tt.message {
+			t.Errorf("expected message %q, got %q", tt.message, pe.Message)
+		}
+		if len(pe.Notes) != 1 || !strings.Contains(pe.Notes[0].Message, "<Value string>") && !strings.Contains(pe.Notes[0].Message, "<Service string>") {
+			t.Errorf("expected signature note, got %+v", pe.Notes)
+		}
+	}
+}
+
+func TestPrefixNot(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`_: 3; !ServiceUp "ftp"`, "NOT (Service 'ftp' is running)"},
+		{`_: 3; not ServiceUp "ftp"`, "NOT (Service 'ftp' is running)"},
+		{`_: 3; !(ServiceUp "ftp" || ServiceUp "vsftpd")`, "NOT (Service 'ftp' is running OR Service 'vsftpd' is running)"},
+		{`_: 3; not(ServiceUp "ftp") && !!PathExists "/"`, "(NOT (Service 'ftp' is running) AND NOT (NOT (Path '/' exists)))"},
+	}
+
+	for _, tt := range tests {
+		checks, err := buildChecks(tt.source)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.source, err)
+		}
+		if checks[0].Message != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.source, tt.want, checks[0].Message)
+		}
+	}
+}
+
+func TestXorAndImplies(t *testing.T) {
+	// every test function passes, so its Not variant fails
+	tests := []struct {
+		source  string
+		message string
+		score   bool
+	}{
+		{`_: 3; ServiceUp "a" ^^ ServiceUp "b"`, "(Service 'a' is running XOR Service 'b' is running)", false},
+		{`_: 3; ServiceUp "a" ^^ ServiceUpNot "b" && ServiceUp "c"`, "(Service 'a' is running XOR (NOT (Service 'b' is running) AND Service 'c' is running))", true},
+		{`_: 3; ServiceUp "a" ^^ ServiceUpNot "b" ^^ ServiceUpNot "c"`, "(exactly 1 of: Service 'a' is running, NOT (Service 'b' is running), NOT (Service 'c' is running))", true},
+		{`_: 3; ServiceUp "a" ^^ ServiceUp "b" ^^ ServiceUp "c"`, "(exactly 1 of: Service 'a' is running, Service 'b' is running, Service 'c' is running)", false},
+		{`_: 3; ServiceUp "a" || ServiceUp "b" ^^ ServiceUp "c"`, "(Service 'a' is running OR (Service 'b' is running XOR Service 'c' is running))", true},
+		{`_: 3; ServiceUp "a" => PathExistsNot "/"`, "(IF Service 'a' is running THEN NOT (Path '/' exists))", false},
+		{`_: 3; ServiceUpNot "a" => PathExistsNot "/"`, "(IF NOT (Service 'a' is running) THEN NOT (Path '/' exists))", true},
+		{`_: 3; ServiceUp "a" => ServiceUp "b" => PathExistsNot "/"`, "(IF Service 'a' is running THEN (IF Service 'b' is running THEN NOT (Path '/' exists)))", false},
+		{"_: 3\n\tServiceUp \"apache2\" =>\n\tFileContains \"/etc/apache2/apache2.conf\" \"Options -Indexes\" || PathExistsNot \"/\"", "(IF Service 'apache2' is running THEN (File '/etc/apache2/apache2.conf' contains 'Options -Indexes' OR NOT (Path '/' exists)))", true},
+	}
+
+	for _, tt := range tests {
+		checks, err := buildChecks(tt.source)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.source, err)
+		}
+		if checks[0].Message != tt.message {
+			t.Errorf("%s: expected %q, got %q", tt.source, tt.message, checks[0].Message)
+		}
+		if score := checks[0].Condition.Score(); score != tt.score {
+			t.Errorf("%s: expected score %v, got %v", tt.source, tt.score, score)
+		}
+	}
+}
+
+func TestThresholds(t *testing.T) {
+	// every test function passes, so its Not variant fails
+	tests := []struct {
+		source  string
+		message string
+		score   bool
+	}{
+		{`_: 3; atleast 2 (ServiceUp "a", ServiceUpNot "b", PathExists "/")`, "(at least 2 of: Service 'a' is running, NOT (Service 'b' is running), Path '/' exists)", true},
+		{`_: 3; exactly 1 (ServiceUp "a", PathExists "/")`, "(exactly 1 of: Service 'a' is running, Path '/' exists)", false},
+		{`_: 3; atmost 1 (ServiceUpNot "a", PathExists "/") && ServiceUp "c"`, "((at most 1 of: NOT (Service 'a' is running), Path '/' exists) AND Service 'c' is running)", true},
+		{`_: 3; !exactly 2 (ServiceUp "a" || ServiceUpNot "b", PathExists "/")`, "NOT (exactly 2 of: (Service 'a' is running OR NOT (Service 'b' is running)), Path '/' exists)", false},
+		{"_: 3\n\tatleast 1 (\n\t\tServiceUpNot \"a\",\n\t\tPathExists \"/\" [\"hint\"],\n\t)", "(at least 1 of: NOT (Service 'a' is running), Path '/' exists)", true},
+	}
+
+	for _, tt := range tests {
+		checks, err := buildChecks(tt.source)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.source, err)
+		}
+		if checks[0].Message != tt.message {
+			t.Errorf("%s: expected %q, got %q", tt.source, tt.message, checks[0].Message)
+		}
+		if score := checks[0].Condition.Score(); score != tt.score {
+			t.Errorf("%s: expected score %v, got %v", tt.source, tt.score, score)
+		}
+	}
+
+	checks, _ := buildChecks("\"a\": 3\n\tPathExists \"/\"\n\tatleast 1 (ServiceUp \"a\", ServiceUp \"b\")")
+	threshold := checks[0].Condition.(*aeaconf2.AndExpr).Rhs.(*aeaconf2.ThresholdExpr)
+	if span := threshold.Span; span.Start.Column != 2 || span.End.Column != 42 {
+		t.Errorf("expected the threshold's span to cover it, got %+v", span)
+	}
+}
+
+func TestThresholdErrors(t *testing.T) {
+	tests := []struct {
+		source  string
+		code    string
+		message string
+	}{
+		{`"a": 3; atleast (ServiceUp "a")`, aeaconf2.CodeSyntax, "expected number, found '(': expected the number of conditions following 'atleast'"},
+		{`"a": 3; atleast -1 (ServiceUp "a")`, aeaconf2.CodeSyntax, "expected a non-negative number of conditions following 'atleast', found -1"},
+		{`"a": 3; exactly 1 ServiceUp "a"`, aeaconf2.CodeSyntax, "expected '(', found name `ServiceUp`: expected '(' to open the conditions of 'exactly 1'"},
+		{`"a": 3; atmost 1 (ServiceUp "a"]`, aeaconf2.CodeUnclosedParen, "expected ',' or ')' in the conditions of 'atmost 1', found ']'"},
+		{`"a": 3; atleast 1 ()`, aeaconf2.CodeSyntax, "expected at least one condition for 'atleast 1'"},
+		{`"a": 3; atleast 99999999999999999999 (ServiceUp "a")`, aeaconf2.CodeSyntax, "number 99999999999999999999 is out of range"},
+		{`"a": 99999999999999999999; ServiceUp "a"`, aeaconf2.CodeSyntax, "number 99999999999999999999 is out of range"},
+	}
+
+	for _, tt := range tests {
+		_, err := buildChecks(tt.source)
+		var pe *aeaconf2.ParseError
+		if !errors.As(err, &pe) {
+			t.Fatalf("%s: expected *ParseError, got %v", tt.source, err)
+		}
+		if pe.Code != tt.code || pe.Message != tt.message {
+			t.Errorf("%s: expected %s %q, got %s %q", tt.source, tt.code, tt.message, pe.Code, pe.Message)
+		}
+	}
+}
+
+func TestTypedArguments(t *testing.T) {
+	checks, err := buildChecks("\"a\": 3\n\tFilePermissions \"/etc/shadow\" 0640\n\tPasswordPolicy 90 true 2.5 15m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	and := checks[0].Condition.(*aeaconf2.AndExpr)
+	if mode := and.Lhs.(*FilePermissions).Mode; mode != 0640 {
+		t.Errorf("expected mode 0640, got %o", mode)
+	}
+	policy := and.Rhs.(*PasswordPolicy)
+	if policy.MaxDays != 90 || !policy.Enforced || policy.MinEntropy != 2.5 || policy.Lockout != 15*time.Minute {
+		t.Errorf("unexpected arguments: %+v", policy)
+	}
+
+	// integers too large for an int are still valid floats
+	checks, err = buildChecks(`"a": 3; PasswordPolicy 90 true 99999999999999999999 15m`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entropy := checks[0].Condition.(*PasswordPolicy).MinEntropy; entropy != 1e20 {
+		t.Errorf("expected 1e20, got %g", entropy)
+	}
+
+	checks, err = buildChecks(`"a": 3; PasswordPolicy 90 true 3.5e39 15m`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entropy := checks[0].Condition.(*PasswordPolicy).MinEntropy; entropy != 3.5e39 {
+		t.Errorf("expected 3.5e39, got %g", entropy)
+	}
+}
+
+func TestTypedArgumentErrors(t *testing.T) {
+	tests := []struct {
+		source  string
+		message string
+	}{
+		{`"a": 3; FilePermissions "/etc/shadow" 0648`, "expected an octal file mode (e.g. 0644) for argument 'mode' of 'FilePermissions', found number `0648`"},
+		{`"a": 3; FilePermissions "/etc/shadow" "0640"`, "expected an octal file mode (e.g. 0644) for argument 'mode' of 'FilePermissions', found string `\"0640\"`"},
+		{`"a": 3; PasswordPolicy "90" true 2.5 15m`, "expected an integer for argument 'MaxDays' of 'PasswordPolicy', found string `\"90\"`"},
+		{`"a": 3; PasswordPolicy 90 yes 2.5 15m`, "expected true or false for argument 'Enforced' of 'PasswordPolicy', found name `yes`"},
+		{`"a": 3; PasswordPolicy 90 true 2.5 15`, "expected a duration (e.g. 30s or 1h30m) for argument 'Lockout' of 'PasswordPolicy', found number `15`"},
+		{`"a": 3; PasswordPolicy 90 true 2.5 15y`, "invalid duration '15y': expected e.g. 30s, 5m or 1h30m (units: ns, us, ms, s, m, h)"},
+	}
+
+	for _, tt := range tests {
+		_, err := buildChecks(tt.source)
+		var pe *aeaconf2.ParseError
+		if !errors.As(err, &pe) {
+			t.Fatalf("%s: expected *ParseError, got %v", tt.source, err)
+		}
+		if pe.Message != tt.message {
+			t.Errorf("%s: expected %q, got %q", tt.source, tt.message, pe.Message)
+		}
+	}
+
+	// registries that weren't checked with CheckFunctionRegistry
+	type Complex struct {
+		aeaconf2.BaseCondition
+		Value complex128
+	}
+	registry := map[string]reflect.Type{"Complex": reflect.TypeOf(Complex{})}
+	_, err := aeaconf2.DefaultAeaconfBuilder([]byte(`"a": 3; Complex 1`), registry).Build()
+	var pe *aeaconf2.ParseError
+	if !errors.As(err, &pe) || pe.Message != "argument 'Value' of 'Complex' has unsupported type 'complex128'" {
+		t.Errorf("expected an unsupported type error, got %v", err)
+	}
+
+	type Ratio struct {
+		aeaconf2.BaseCondition
+		Value float32
+	}
+	registry = map[string]reflect.Type{"Ratio": reflect.TypeOf(Ratio{})}
+	for _, arg := range []string{"3.5e39", "1234567890123456789012345678901234567890123456"} {
+		_, err = aeaconf2.DefaultAeaconfBuilder([]byte(`"a": 3; Ratio `+arg), registry).Build()
+		want := "argument 'Value' of 'Ratio' is out of range: " + arg
+		if !errors.As(err, &pe) || pe.Message != want {
+			t.Errorf("expected %q, got %v", want, err)
+		}
+	}
+}
+
+func TestNamedArguments(t *testing.T) {
+	checks, err := buildChecks("\"a\": 3\n\tFileContains Value=\"PermitRootLogin no\" File=\"/etc/ssh/sshd_config\"\n\tFilePermissions \"/etc/shadow\" mode=0640")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	and := checks[0].Condition.(*aeaconf2.AndExpr)
+	contains := and.Lhs.(*FileContains)
+	if contains.File != "/etc/ssh/sshd_config" || contains.Value != "PermitRootLogin no" {
+		t.Errorf("unexpected arguments: %+v", contains)
+	}
+	if perms := and.Rhs.(*FilePermissions); perms.Path != "/etc/shadow" || perms.Mode != 0640 {
+		t.Errorf("unexpected arguments: %+v", perms)
+	}
+}
+
+func TestNamedArgumentErrors(t *testing.T) {
+	tests := []struct {
+		source  string
+		code    string
+		message string
+	}{
+		{`"a": 3; FileContains File="/etc/passwd" Valu="root"`, aeaconf2.CodeUnknownArgument, "'FileContains' has no argument named 'Valu'"},
+		{`"a": 3; FileContains "/etc/passwd" File="/etc/shadow"`, aeaconf2.CodeDuplicateArgument, "argument 'File' of 'FileContains' is given more than once"},
+		{`"a": 3; FileContains File="/etc/passwd" "root"`, aeaconf2.CodeSyntax, "positional arguments to 'FileContains' must come before named arguments"},
+		{`"a": 3; FileContains Value="root"`, aeaconf2.CodeArgumentCount, "missing argument 'File' for 'FileContains': expected 2 arguments, found 1"},
+		{`"a": 3; FilePermissions path="/etc/shadow" mode="0640"`, aeaconf2.CodeArgumentType, "expected an octal file mode (e.g. 0644) for argument 'mode' of 'FilePermissions', found string `\"0640\"`"},
+	}
+
+	for _, tt := range tests {
+		_, err := buildChecks(tt.source)
+		var pe *aeaconf2.ParseError
+		if !errors.As(err, &pe) {
+			t.Fatalf("%s: expected *ParseError, got %v", tt.source, err)
+		}
+		if pe.Code != tt.code || pe.Message != tt.message {
+			t.Errorf("%s: expected %s %q, got %s %q", tt.source, tt.code, tt.message, pe.Code, pe.Message)
+		}
+	}
+
+	_, err := buildChecks(`"a": 3; FileContains File="/etc/passwd" Valu="root"`)
+	var pe *aeaconf2.ParseError
+	errors.As(err, &pe)
+	if len(pe.Notes) == 0 || pe.Notes[0].Message != "did you mean `Value`?" {
+		t.Errorf("expected suggestion note, got %+v", pe.Notes)
+	}
+}
+
+func TestOptionalArguments(t *testing.T) {
+	checks, err := buildChecks("\"a\": 3\n\tFileOwner \"/etc/shadow\"\n\tFileOwner \"/etc/gshadow\" Group=\"shadow\"")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	and := checks[0].Condition.(*aeaconf2.AndExpr)
+	if owner := and.Lhs.(*FileOwner); owner.Owner != "root" || owner.Group != "" {
+		t.Errorf("expected defaults, got %+v", owner)
+	}
+	if owner := and.Rhs.(*FileOwner); owner.Owner != "root" || owner.Group != "shadow" {
+		t.Errorf("expected default owner and given group, got %+v", owner)
+	}
+
+	tests := []struct {
+		source  string
+		message string
+	}{
+		{`"a": 3; FileOwner`, "missing argument 'Path' for 'FileOwner': expected 1 to 3 arguments, found 0"},
+		{`"a": 3; FileOwner "/etc/shadow" "root" "shadow" "extra"`, "too many arguments for 'FileOwner': expected 1 to 3 arguments, found extra string `\"extra\"`"},
+	}
+	for _, tt := range tests {
+		_, err := buildChecks(tt.source)
+		var pe *aeaconf2.ParseError
+		if !errors.As(err, &pe) {
+			t.Fatalf("%s: expected *ParseError, got %v", tt.source, err)
+		}
+		if pe.Message != tt.message {
+			t.Errorf("%s: expected %q, got %q", tt.source, tt.message, pe.Message)
+		}
+		if note := "expected signature: FileOwner <Path string> [<Owner string = root>] [<Group string>]"; pe.Notes[len(pe.Notes)-1].Message != note {
+			t.Errorf("%s: expected note %q, got %+v", tt.source, note, pe.Notes)
+		}
+	}
+
+	// registries that weren't checked with CheckFunctionRegistry
+	type Retries struct {
+		aeaconf2.BaseCondition
+		Count   int  `acf:"default=abc"`
+		Verbose bool `acf:"default=1"`
+	}
+	registry := map[string]reflect.Type{"Retries": reflect.TypeOf(Retries{})}
+	for source, message := range map[string]string{
+		`"a": 3; Retries`:   "invalid default for argument 'Count' of 'Retries': invalid integer 'abc'",
+		`"a": 3; Retries 3`: "invalid default for argument 'Verbose' of 'Retries': invalid boolean '1': expected true or false",
+	} {
+		_, err := aeaconf2.DefaultAeaconfBuilder([]byte(source), registry).Build()
+		var pe *aeaconf2.ParseError
+		if !errors.As(err, &pe) || pe.Message != message || pe.Column != 9 {
+			t.Errorf("%s: expected %q at the call, got %v", source, message, err)
+		}
+	}
+}
+
+func TestListArguments(t *testing.T) {
+	source := `"a": 3
+	UsersAbsent "games" "news" [ "hint" ]
+	UsersAbsent {}
+	PortsClosed { 21, 23 } "10.0.0.1"
+	PortsClosed Ports={
+		111,
+		2049,
+	}`
+	checks, err := buildChecks(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var funcs []aeaconf2.Condition
+	var collect func(cond aeaconf2.Condition)
+	collect = func(cond aeaconf2.Condition) {
+		if and, ok := cond.(*aeaconf2.AndExpr); ok {
+			collect(and.Lhs)
+			collect(and.Rhs)
+		} else {
+			funcs = append(funcs, cond)
+		}
+	}
+	collect(checks[0].Condition)
+
+	if users := funcs[0].(*UsersAbsent); !reflect.DeepEqual(users.Users, []string{"games", "news"}) || users.Hint != "hint" {
+		t.Errorf("unexpected repeated arguments: %+v", users)
+	}
+	if users := funcs[1].(*UsersAbsent); len(users.Users) != 0 {
+		t.Errorf("expected an empty list, got %+v", users)
+	}
+	if ports := funcs[2].(*PortsClosed); !reflect.DeepEqual(ports.Ports, []int{21, 23}) || ports.Host != "10.0.0.1" {
+		t.Errorf("unexpected list literal: %+v", ports)
+	}
+	if ports := funcs[3].(*PortsClosed); !reflect.DeepEqual(ports.Ports, []int{111, 2049}) || ports.Host != "localhost" {
+		t.Errorf("unexpected multi-line list: %+v", ports)
+	}
+}
+
+func TestListArgumentErrors(t *testing.T) {
+	tests := []struct {
+		source  string
+		message string
+	}{
+		{`"a": 3; UsersAbsent "games" 3`, "expected a string for argument 'Users' of 'UsersAbsent', found number `3`"},
+		{`"a": 3; UsersAbsent { "games" "news" }`, "expected ',' or '}' in list for argument 'Users' of 'UsersAbsent', found string `\"news\"`"},
+		{`"a": 3; UsersAbsent "games" { "news" }`, "argument 'Users' of 'UsersAbsent' is given both as repeated arguments and as a list"},
+		{`"a": 3; PortsClosed 21 23`, "expected a string for argument 'Host' of 'PortsClosed', found number `23`"},
+		{`"a": 3; UsersAbsent`, "missing argument 'Users' for 'UsersAbsent': expected 1 argument, found 0"},
+	}
+
+	for _, tt := range tests {
+		_, err := buildChecks(tt.source)
+		var pe *aeaconf2.ParseError
+		if !errors.As(err, &pe) {
+			t.Fatalf("%s: expected *ParseError, got %v", tt.source, err)
+		}
+		if pe.Message != tt.message {
+			t.Errorf("%s: expected %q, got %q", tt.source, tt.message, pe.Message)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package aeaconf2
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"unicode/utf8"
+)
+
+type jsonPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+type jsonSpan struct {
+	File  string       `json:"file"`
+	Start jsonPosition `json:"start"`
+	End   jsonPosition `json:"end"`
+}
+
+type jsonNote struct {
+	Message string    `json:"message"`
+	Span    *jsonSpan `json:"span,omitempty"`
+}
+
+type jsonDiagnostic struct {
+	Severity string     `json:"severity"`
+	Stage    string     `json:"stage"`
+	Code     string     `json:"code"`
+	Message  string     `json:"message"`
+	Span     *jsonSpan  `json:"span,omitempty"`
+	Notes    []jsonNote `json:"notes,omitempty"`
+}
+
+func toJSONSpan(span Span) *jsonSpan {
+	if !span.IsValid() {
+		return nil
+	}
+	return &jsonSpan{
+		File:  span.Start.File,
+		Start: jsonPosition{Line: span.Start.Line, Column: span.Start.Column, Offset: span.Start.Offset},
+		End:   jsonPosition{Line: span.End.Line, Column: span.End.Column, Offset: span.End.Offset},
+	}
+}
+
+// WriteDiagnosticsJSON writes each diagnostic as a single line of JSON:
+//
+//	{"severity":"error","stage":"parser","code":"unknown-function","message":"...","span":{...}}
+func WriteDiagnosticsJSON(w io.Writer, diags []*Diagnostic) error {
+	enc := json.NewEncoder(w)
+	for _, d := range diags {
+		out := jsonDiagnostic{
+			Severity: d.Severity.String(),
+			Stage:    d.Stage.String(),
+			Code:     d.Code,
+			Message:  d.Message,
+			Span:     toJSONSpan(d.Span),
+		}
+		for _, note := range d.Notes {
+			out.Notes = append(out.Notes, jsonNote{Message: note.Message, Span: toJSONSpan(note.Span)})
+		}
+
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations,omitempty"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+	Properties       sarifProperties `json:"properties"`
+}
+
+// sarifProperties are the fields of a diagnostic SARIF has no place for
+type sarifProperties struct {
+	Stage string `json:"stage"`
+}
+
+type sarifLocation struct {
+	ID               *int                  `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+// sarifColumn converts a byte column into the code point column SARIF expects
+func (d *Diagnostic) sarifColumn(pos Position) int {
+	source := d.sourceOf(Span{Start: pos, End: pos})
+	if source == nil {
+		return pos.Column
+	}
+	line := source.LineText(pos.Line)
+	return utf8.RuneCount(line[:min(pos.Column-1, len(line))]) + 1
+}
+
+// sarifLocation converts a span into a location, reporting false for spans
+// with no file name (e.g. checks built without AeaconfBuilder.FileName),
+// which SARIF can't locate
+func (d *Diagnostic) sarifLocation(span Span) (sarifLocation, bool) {
+	if !span.IsValid() || span.Start.File == "" {
+		return sarifLocation{}, false
+	}
+	return sarifLocation{
+		PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: span.Start.File},
+			Region: sarifRegion{
+				StartLine:   span.Start.Line,
+				StartColumn: d.sarifColumn(span.Start),
+				EndLine:     span.End.Line,
+				EndColumn:   d.sarifColumn(span.End),
+			},
+		},
+	}, true
+}
+
+// WriteDiagnosticsSARIF writes the diagnostics as a SARIF 2.1.0 log with a
+// single run, using diagnostic codes as rule IDs and recording the stage
+// as a property. Notes with a location become related locations; the rest
+// are appended to the result message.
+func WriteDiagnosticsSARIF(w io.Writer, diags []*Diagnostic) error {
+	ruleIDs := make(map[string]bool)
+	results := []sarifResult{}
+
+	for _, d := range diags {
+		ruleIDs[d.Code] = true
+
+		result := sarifResult{
+			RuleID:     d.Code,
+			Level:      d.Severity.String(),
+			Message:    sarifMessage{Text: d.Message},
+			Properties: sarifProperties{Stage: d.Stage.String()},
+		}
+		if location, ok := d.sarifLocation(d.Span); ok {
+			result.Locations = append(result.Locations, location)
+		}
+
+		for _, note := range d.Notes {
+			related, ok := d.sarifLocation(note.Span)
+			if !ok {
+				result.Message.Text += "\nnote: " + note.Message
+				continue
+			}
+			id := len(result.RelatedLocations)
+			related.ID = &id
+			related.Message = &sarifMessage{Text: note.Message}
+			result.RelatedLocations = append(result.RelatedLocations, related)
+		}
+		results = append(results, result)
+	}
+
+	rules := []sarifRule{}
+	for id := range ruleIDs {
+		rules = append(rules, sarifRule{ID: id})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "aeaconf2",
+				InformationURI: "https://github.com/safinsingh/aeaconf2",
+				Rules:          rules,
+			}},
+			ColumnKind: "unicodeCodePoints",
+			Results:    results,
+		}},
+	})
+}
@@ -0,0 +1,110 @@
+package aeaconf2_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/safinsingh/aeaconf2"
+)
+
+func buildErrors(t *testing.T, source string) aeaconf2.ParseErrors {
+	_, err := aeaconf2.DefaultAeaconfBuilder([]byte(source), getFunctionRegistry()).
+		SetFileName("checks.acf").
+		Build()
+	errs, ok := err.(aeaconf2.ParseErrors)
+	if !ok {
+		t.Fatalf("expected ParseErrors, got %v", err)
+	}
+	return errs
+}
+
+func TestWriteDiagnosticsJSON(t *testing.T) {
+	errs := buildErrors(t, "\"a\": 3; ServiceUpp \"sshd\"\n\"b\": 3; (ServiceUp \"sshd\"")
+
+	var buf bytes.Buffer
+	if err := aeaconf2.WriteDiagnosticsJSON(&buf, errs.Diagnostics()); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d:\n%s", len(lines), buf.String())
+	}
+
+	var diag struct {
+		Severity string
+		Stage    string
+		Code     string
+		Span     struct {
+			File  string
+			Start struct{ Line, Column int }
+		}
+		Notes []struct{ Message string }
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &diag); err != nil {
+		t.Fatal(err)
+	}
+	if diag.Severity != "error" || diag.Stage != "parser" || diag.Code != aeaconf2.CodeUnclosedParen ||
+		diag.Span.File != "checks.acf" || diag.Span.Start.Line != 2 || len(diag.Notes) != 2 {
+		t.Errorf("unexpected diagnostic: %s", lines[1])
+	}
+}
+
+func TestWriteDiagnosticsSARIF(t *testing.T) {
+	errs := buildErrors(t, "\"a\": 3; ServiceUpp \"sshd\"")
+
+	var buf bytes.Buffer
+	if err := aeaconf2.WriteDiagnosticsSARIF(&buf, errs.Diagnostics()); err != nil {
+		t.Fatal(err)
+	}
+
+	var log struct {
+		Version string
+		Runs    []struct {
+			Results []struct {
+				RuleID     string
+				Level      string
+				Message    struct{ Text string }
+				Properties struct{ Stage string }
+				Locations  []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string }
+						Region           struct{ StartLine, StartColumn, EndColumn int }
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+
+	result := log.Runs[0].Results[0]
+	location := result.Locations[0].PhysicalLocation
+	if log.Version != "2.1.0" || result.RuleID != aeaconf2.CodeUnknownFunction || result.Level != "error" ||
+		result.Properties.Stage != "parser" ||
+		!strings.Contains(result.Message.Text, "did you mean `ServiceUp`?") ||
+		location.ArtifactLocation.URI != "checks.acf" ||
+		location.Region.StartLine != 1 || location.Region.StartColumn != 9 || location.Region.EndColumn != 19 {
+		t.Errorf("unexpected SARIF log:\n%s", buf.String())
+	}
+}
+
+func TestWriteDiagnosticsSARIFWithoutFileName(t *testing.T) {
+	_, err := buildChecks("\"a\": 3; ServiceUpp \"sshd\"")
+	errs, ok := err.(aeaconf2.ParseErrors)
+	if !ok {
+		t.Fatalf("expected ParseErrors, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := aeaconf2.WriteDiagnosticsSARIF(&buf, errs.Diagnostics()); err != nil {
+		t.Fatal(err)
+	}
+	// SARIF can't locate a result without a file, so it has no location
+	if strings.Contains(buf.String(), `"uri"`) || strings.Contains(buf.String(), `"locations"`) {
+		t.Errorf("expected no locations without a file name:\n%s", buf.String())
+	}
+}
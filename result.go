@@ -0,0 +1,110 @@
+package aeaconf2
+
+import (
+	"context"
+	"fmt"
+)
+
+// ResultStatus is the outcome of evaluating a condition. Errors and skips
+// are unknown outcomes, which propagate through operators as in Kleene's
+// three-valued logic: e.g. `A && B` fails if either fails, whatever the
+// other is, but is unknown if neither fails and either is unknown.
+type ResultStatus int
+
+const (
+	ResultPass ResultStatus = iota
+	ResultFail
+	// the condition couldn't be evaluated, e.g. a file couldn't be read
+	ResultError
+	// the condition wasn't evaluated, e.g. it doesn't apply on this system
+	ResultSkip
+)
+
+func (s ResultStatus) String() string {
+	switch s {
+	case ResultPass:
+		return "pass"
+	case ResultFail:
+		return "fail"
+	case ResultError:
+		return "error"
+	default:
+		return "skip"
+	}
+}
+
+type Result struct {
+	Status ResultStatus
+	// why the condition wasn't evaluated, for errors and (optionally) skips
+	Err error
+}
+
+// BoolResult is a pass for true and a fail for false
+func BoolResult(passed bool) Result {
+	if passed {
+		return Result{Status: ResultPass}
+	}
+	return Result{Status: ResultFail}
+}
+
+func ErrorResult(err error) Result {
+	return Result{Status: ResultError, Err: err}
+}
+
+func SkipResult(reason error) Result {
+	return Result{Status: ResultSkip, Err: reason}
+}
+
+// Known reports whether the result is a pass or fail
+func (r Result) Known() bool {
+	return r.Status == ResultPass || r.Status == ResultFail
+}
+
+func (r Result) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("%s: %s", r.Status, r.Err)
+	}
+	return r.Status.String()
+}
+
+// Evaluator is implemented by conditions that can tell an error or skip
+// apart from failing. Conditions without it are evaluated with Score.
+type Evaluator interface {
+	Evaluate(ctx context.Context) Result
+}
+
+// Evaluate evaluates a condition with its Evaluate method, or adapts its
+// Score if it has none. Once ctx is done, conditions error with ctx.Err(),
+// so that checks cut short aren't mistaken for ones that don't apply.
+func Evaluate(ctx context.Context, cond Condition) Result {
+	if err := ctx.Err(); err != nil {
+		return ErrorResult(err)
+	}
+	if evaluator, ok := cond.(Evaluator); ok {
+		return evaluator.Evaluate(ctx)
+	}
+	return BoolResult(cond.Score())
+}
+
+// unknown combines two results, at least one of them unknown: errors take
+// precedence over skips, and the first of each is kept
+func unknown(a Result, b Result) Result {
+	switch {
+	case a.Known():
+		return b
+	case b.Known():
+		return a
+	case b.Status == ResultError && a.Status != ResultError:
+		return b
+	default:
+		return a
+	}
+}
+
+// not swaps passes and fails, leaving unknown results alone
+func not(r Result) Result {
+	if r.Known() {
+		return BoolResult(r.Status == ResultFail)
+	}
+	return r
+}
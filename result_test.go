@@ -0,0 +1,121 @@
+package aeaconf2_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/safinsingh/aeaconf2"
+)
+
+// fixedResult is a condition that always evaluates to Result
+type fixedResult struct {
+	aeaconf2.BaseCondition
+	Result aeaconf2.Result
+}
+
+func (f *fixedResult) Score() bool {
+	return f.Result.Status == aeaconf2.ResultPass
+}
+
+func (f *fixedResult) DefaultString() string {
+	return f.Result.String()
+}
+
+func (f *fixedResult) Evaluate(ctx context.Context) aeaconf2.Result {
+	return f.Result
+}
+
+var (
+	errUnreadable = errors.New("permission denied")
+
+	passing  = &fixedResult{Result: aeaconf2.BoolResult(true)}
+	failing  = &fixedResult{Result: aeaconf2.BoolResult(false)}
+	erroring = &fixedResult{Result: aeaconf2.ErrorResult(errUnreadable)}
+	skipped  = &fixedResult{Result: aeaconf2.SkipResult(nil)}
+)
+
+func threshold(kind aeaconf2.ThresholdKind, count int, conds ...aeaconf2.Condition) aeaconf2.Condition {
+	return &aeaconf2.ThresholdExpr{Kind: kind, Count: count, Conditions: conds}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name string
+		cond aeaconf2.Condition
+		want aeaconf2.ResultStatus
+	}{
+		{"and fail", &aeaconf2.AndExpr{Lhs: passing, Rhs: failing}, aeaconf2.ResultFail},
+		{"and fail beats error", &aeaconf2.AndExpr{Lhs: erroring, Rhs: failing}, aeaconf2.ResultFail},
+		{"and error", &aeaconf2.AndExpr{Lhs: passing, Rhs: erroring}, aeaconf2.ResultError},
+		{"and error beats skip", &aeaconf2.AndExpr{Lhs: skipped, Rhs: erroring}, aeaconf2.ResultError},
+		{"and skip", &aeaconf2.AndExpr{Lhs: skipped, Rhs: passing}, aeaconf2.ResultSkip},
+		{"and pass", &aeaconf2.AndExpr{Lhs: passing, Rhs: passing}, aeaconf2.ResultPass},
+		{"or pass beats error", &aeaconf2.OrExpr{Lhs: erroring, Rhs: passing}, aeaconf2.ResultPass},
+		{"or error", &aeaconf2.OrExpr{Lhs: failing, Rhs: erroring}, aeaconf2.ResultError},
+		{"or skip", &aeaconf2.OrExpr{Lhs: skipped, Rhs: failing}, aeaconf2.ResultSkip},
+		{"or fail", &aeaconf2.OrExpr{Lhs: failing, Rhs: failing}, aeaconf2.ResultFail},
+		{"not error", &aeaconf2.NotFunc{Func: erroring}, aeaconf2.ResultError},
+		{"not pass", &aeaconf2.NotFunc{Func: passing}, aeaconf2.ResultFail},
+		{"xor", &aeaconf2.XorExpr{Lhs: passing, Rhs: failing}, aeaconf2.ResultPass},
+		{"xor skip", &aeaconf2.XorExpr{Lhs: passing, Rhs: skipped}, aeaconf2.ResultSkip},
+		{"implies false premise", &aeaconf2.ImpliesExpr{Lhs: failing, Rhs: erroring}, aeaconf2.ResultPass},
+		{"implies true conclusion", &aeaconf2.ImpliesExpr{Lhs: erroring, Rhs: passing}, aeaconf2.ResultPass},
+		{"implies error", &aeaconf2.ImpliesExpr{Lhs: passing, Rhs: erroring}, aeaconf2.ResultError},
+		{"implies skip", &aeaconf2.ImpliesExpr{Lhs: skipped, Rhs: failing}, aeaconf2.ResultSkip},
+		{"atleast decided", threshold(aeaconf2.AtLeast, 2, passing, passing, erroring), aeaconf2.ResultPass},
+		{"atleast undecided", threshold(aeaconf2.AtLeast, 2, passing, erroring, failing), aeaconf2.ResultError},
+		{"atleast unreachable", threshold(aeaconf2.AtLeast, 2, failing, failing, erroring), aeaconf2.ResultFail},
+		{"exactly undecided", threshold(aeaconf2.Exactly, 1, passing, skipped), aeaconf2.ResultSkip},
+		{"exactly exceeded", threshold(aeaconf2.Exactly, 1, passing, passing, erroring), aeaconf2.ResultFail},
+		{"atmost decided", threshold(aeaconf2.AtMost, 1, failing, skipped), aeaconf2.ResultPass},
+		{"adapted score", &aeaconf2.NotFunc{Func: &ServiceUp{Service: "sshd"}}, aeaconf2.ResultFail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := aeaconf2.Evaluate(context.Background(), tt.cond); got.Status != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	result := aeaconf2.Evaluate(context.Background(), &aeaconf2.AndExpr{Lhs: skipped, Rhs: &aeaconf2.NotFunc{Func: erroring}})
+	if !errors.Is(result.Err, errUnreadable) {
+		t.Errorf("expected the error to propagate, got %s", result)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if result := aeaconf2.Evaluate(ctx, passing); result.Status != aeaconf2.ResultError || !errors.Is(result.Err, context.Canceled) {
+		t.Errorf("expected an error once the context is done, got %s", result)
+	}
+	check := &aeaconf2.Check{Condition: passing, Precondition: passing, Points: 3}
+	if status, err := check.StatusContext(ctx); status != aeaconf2.CheckError || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a check error once the context is done, got %s (%v)", status, err)
+	}
+}
+
+func TestCheckStatusContext(t *testing.T) {
+	tests := []struct {
+		check *aeaconf2.Check
+		want  aeaconf2.CheckStatus
+		err   error
+	}{
+		{&aeaconf2.Check{Condition: passing, Points: 3}, aeaconf2.CheckPassed, nil},
+		{&aeaconf2.Check{Condition: erroring, Points: 3}, aeaconf2.CheckError, errUnreadable},
+		{&aeaconf2.Check{Condition: skipped, Points: 3}, aeaconf2.CheckNotApplicable, nil},
+		{&aeaconf2.Check{Condition: passing, Precondition: skipped, Points: 3}, aeaconf2.CheckNotApplicable, nil},
+		{&aeaconf2.Check{Condition: passing, Precondition: erroring, Points: 3}, aeaconf2.CheckError, errUnreadable},
+	}
+
+	for _, tt := range tests {
+		status, err := tt.check.StatusContext(context.Background())
+		if status != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("expected %s (%v), got %s (%v)", tt.want, tt.err, status, err)
+		}
+		if points := tt.check.PointsFor(status); (status == aeaconf2.CheckPassed) != (points == 3) {
+			t.Errorf("%s: unexpected points %d", status, points)
+		}
+	}
+}
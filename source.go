@@ -0,0 +1,90 @@
+package aeaconf2
+
+import (
+	"bytes"
+	"sort"
+)
+
+// Position is a location in a source file. Line is 1-based and includes
+// the file's line offset (e.g. the header preceding the checks); Column is
+// the 1-based byte column within that line.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+	Offset int
+}
+
+// Span is the source range [Start, End) of a token or AST node
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// IsValid reports whether the span points into a source file at all
+func (s Span) IsValid() bool {
+	return s.Start.Line > 0
+}
+
+// Join returns the span covering both s and other, assuming s comes first
+func (s Span) Join(other Span) Span {
+	return Span{Start: s.Start, End: other.End}
+}
+
+type SourceFile struct {
+	Name       string
+	Content    []byte
+	LineOffset int
+	// byte offset at which each line begins; lineStarts[0] is always 0
+	lineStarts []int
+}
+
+// trimSource drops the trailing whitespace of a checks file, which would
+// otherwise end it with empty lines. Leading whitespace is kept, so that
+// positions match the file.
+func trimSource(content []byte) []byte {
+	return bytes.TrimRight(content, " \t\r\n")
+}
+
+func NewSourceFile(name string, content []byte, lineOffset int) *SourceFile {
+	lineStarts := []int{0}
+	for i, b := range content {
+		if b == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	return &SourceFile{Name: name, Content: content, LineOffset: lineOffset, lineStarts: lineStarts}
+}
+
+func (f *SourceFile) Position(offset int) Position {
+	// index of the last line starting at or before offset
+	line := sort.Search(len(f.lineStarts), func(i int) bool {
+		return f.lineStarts[i] > offset
+	}) - 1
+
+	return Position{
+		File:   f.Name,
+		Line:   line + 1 + f.LineOffset,
+		Column: offset - f.lineStarts[line] + 1,
+		Offset: offset,
+	}
+}
+
+func (f *SourceFile) Span(start int, end int) Span {
+	return Span{Start: f.Position(start), End: f.Position(end)}
+}
+
+// LineText returns the content of a line (as numbered by Position),
+// without its trailing newline
+func (f *SourceFile) LineText(line int) []byte {
+	idx := line - 1 - f.LineOffset
+	if idx < 0 || idx >= len(f.lineStarts) {
+		return nil
+	}
+
+	end := len(f.Content)
+	if idx+1 < len(f.lineStarts) {
+		end = f.lineStarts[idx+1] - 1
+	}
+	return f.Content[f.lineStarts[idx]:end]
+}
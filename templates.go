@@ -0,0 +1,244 @@
+package aeaconf2
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Template is a top-level `template Name(params...)` definition of a whole
+// check, whose strings may refer to the parameters as `${param}`:
+//
+//	template ServiceSecured(svc, conf) "${svc} is secured": 3 ["Look in ${conf}"]
+//		ServiceUp "${svc}"
+//		PathExists "${conf}"
+//
+// Each top-level `Name("arg", ...)` line instantiates it as a check.
+type Template struct {
+	Name   string
+	Params []string
+	// tokens of the check, replayed through the parser on each instantiation
+	Body []*Token
+	// source of the whole definition
+	Span Span
+
+	used bool
+}
+
+// ParseTemplate parses a `template Name(params...)` definition, capturing
+// the check's tokens for InstantiateTemplate
+func (p *Parser) ParseTemplate() {
+	templateToken := p.Consume()
+	nameToken := p.expectDefinitionName(templateToken)
+	name := string(nameToken.Lexeme)
+	p.ExpectTokenType(TokenLParen, fmt.Sprintf("expected '(' to begin the parameters of template '%s'", name))
+
+	var params []string
+	declared := make(map[string]*Token)
+	for p.Peek().Type != TokenRParen {
+		param := p.ExpectTokenType(TokenIdent, fmt.Sprintf("expected a parameter name for template '%s'", name))
+		if first, ok := declared[string(param.Lexeme)]; ok {
+			p.ErrorWithNotes(CodeDuplicateDefinition, param.Span, []Note{{Message: "first declared here", Span: first.Span}},
+				"template '%s' has more than one parameter named '%s'", name, param.Lexeme)
+		}
+		declared[string(param.Lexeme)] = param
+		params = append(params, string(param.Lexeme))
+
+		if p.Peek().Type != TokenComma {
+			break
+		}
+		p.Consume()
+	}
+	p.ExpectTokenType(TokenRParen, fmt.Sprintf("expected ',' or ')' in the parameters of template '%s'", name))
+
+	if existing, ok := p.Templates[name]; ok {
+		p.ErrorWithNotes(CodeDuplicateDefinition, nameToken.Span, []Note{{Message: "first defined here", Span: existing.Span}},
+			"'%s' is already defined", name)
+	}
+
+	body, span := p.captureBlock(templateToken.Span.Join(p.Previous.Span))
+	if len(body) == 0 {
+		p.ErrorAt(span, "expected a check following template '%s'", name)
+	}
+	p.Templates[name] = &Template{Name: name, Params: params, Body: body, Span: span}
+}
+
+// InstantiateTemplate parses a `Name("arg", ...)` line as the check its
+// template describes
+func (p *Parser) InstantiateTemplate() *Check {
+	nameToken := p.Consume()
+	template := p.Templates[string(nameToken.Lexeme)]
+	signature := []Note{{Message: "expected signature: " + template.Signature(), Span: template.Span}}
+	p.ExpectTokenType(TokenLParen, fmt.Sprintf("expected '(' to begin the arguments of template '%s'", template.Name))
+
+	var args []string
+	for p.Peek().Type != TokenRParen {
+		arg := p.Peek()
+		if arg.Type != TokenString {
+			p.ErrorWithNotes(CodeArgumentType, arg.Span, signature, "expected a string argument for template '%s', found %s",
+				template.Name, arg.Describe())
+		}
+		p.Consume()
+		args = append(args, p.Interpolate(arg))
+
+		if p.Peek().Type != TokenComma {
+			break
+		}
+		p.Consume()
+	}
+	closing := p.ExpectTokenType(TokenRParen, fmt.Sprintf("expected ',' or ')' in the arguments of template '%s'", template.Name))
+	span := nameToken.Span.Join(closing.Span)
+
+	if len(args) != len(template.Params) {
+		p.ErrorWithNotes(CodeArgumentCount, span, signature, "template '%s' expects %d argument%s, found %d",
+			template.Name, len(template.Params), plural(len(template.Params)), len(args))
+	}
+	p.expectEndOfLine(fmt.Sprintf("instantiation of template '%s'", template.Name))
+
+	scope := make(map[string]string)
+	for i, param := range template.Params {
+		scope[param] = args[i]
+	}
+	return p.expandTemplate(template, scope, span)
+}
+
+// Signature describes how a template is instantiated, e.g.
+//
+//	ServiceSecured(svc, conf)
+func (t *Template) Signature() string {
+	return fmt.Sprintf("%s(%s)", t.Name, strings.Join(t.Params, ", "))
+}
+
+// expandTemplate parses the check of a template instantiated at span, with
+// its parameters set to the values in scope
+func (p *Parser) expandTemplate(template *Template, scope map[string]string, span Span) *Check {
+	template.used = true
+
+	defer func() {
+		// errors in the check point into the template; say where it was used
+		r := recover()
+		if pe, ok := r.(*ParseError); ok && span != template.Span {
+			pe.AddNote(fmt.Sprintf("in template '%s', instantiated here", template.Name), span)
+		}
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	p.pushReplay(template.Body, template.Span.End)
+	p.scopes = append(p.scopes, scope)
+
+	check := p.NextCheck()
+	if p.SkipUntilIndentedBlock() {
+		p.ErrorWithNotes(CodeSyntax, p.currentSpan(), []Note{{Message: "in template '" + template.Name + "'", Span: template.Span}},
+			"expected end of template, found %s", p.Peek().Describe())
+	}
+
+	p.popReplay()
+	p.scopes = p.scopes[:len(p.scopes)-1]
+
+	// the check stands in for the line instantiating it
+	check.Span = span
+	return check
+}
+
+// checkUnusedTemplates instantiates every template no line has used, with
+// each parameter set to its own name, so that errors in their checks are
+// still reported
+func (p *Parser) checkUnusedTemplates() {
+	var unused []*Template
+	for _, template := range p.Templates {
+		if !template.used {
+			unused = append(unused, template)
+		}
+	}
+	sort.Slice(unused, func(i, j int) bool {
+		return unused[i].Span.Start.Offset < unused[j].Span.Start.Offset
+	})
+
+	for _, template := range unused {
+		scope := make(map[string]string)
+		for _, param := range template.Params {
+			scope[param] = "<" + param + ">"
+		}
+		if pe := catchParseError(func() { p.expandTemplate(template, scope, template.Span) }); pe != nil {
+			pe.files = p.sources.files
+			p.Errors = append(p.Errors, pe)
+			p.resetReplays()
+		}
+	}
+}
+
+// ParseFor parses a `for name in ["value", ...]` loop, followed by a check
+// like that of a template with the single parameter `name`, and returns a
+// check for each value
+func (p *Parser) ParseFor() []*Check {
+	forToken := p.Consume()
+	nameToken := p.ExpectTokenType(TokenIdent, "expected a name following 'for'")
+	name := string(nameToken.Lexeme)
+	if !isKeyword(p.Peek(), "in") {
+		p.Errorf("expected 'in' following 'for %s', found %s", name, p.Peek().Describe())
+	}
+	p.Consume()
+
+	open := p.Consume()
+	closeType := TokenRBracket
+	if open.Type == TokenLBrace {
+		closeType = TokenRBrace
+	} else if open.Type != TokenLBracket {
+		p.ErrorAt(open.Span, "expected a list of strings like [\"a\", \"b\"] following 'for %s in', found %s", name, open.Describe())
+	}
+
+	var values []string
+	for {
+		p.skipLineBreaks()
+		if p.Peek().Type == closeType {
+			break
+		}
+		value := p.ExpectTokenType(TokenString, fmt.Sprintf("expected a string in the list of 'for %s'", name))
+		values = append(values, p.Interpolate(value))
+
+		p.skipLineBreaks()
+		if p.Peek().Type != TokenComma {
+			break
+		}
+		p.Consume()
+	}
+	if p.Peek().Type != closeType {
+		p.ErrorWithNotes(CodeSyntax, p.currentSpan(), []Note{{Message: "list opened here", Span: open.Span}},
+			"expected ',' or %s in the list of 'for %s', found %s", closeType.Describe(), name, p.Peek().Describe())
+	}
+	p.Consume()
+
+	body, span := p.captureBlock(forToken.Span.Join(p.Previous.Span))
+	if len(body) == 0 {
+		p.ErrorAt(span, "expected a check following 'for %s in [...]'", name)
+	}
+	loop := &Template{Name: "for " + name, Params: []string{name}, Body: body, Span: span}
+
+	// an empty loop still has its check parsed, so that its errors are reported
+	if len(values) == 0 {
+		p.expandTemplate(loop, map[string]string{name: "<" + name + ">"}, loop.Span)
+		return nil
+	}
+
+	var checks []*Check
+	for _, value := range values {
+		checks = append(checks, p.expandIteration(loop, name, value))
+	}
+	return checks
+}
+
+// expandIteration parses the check of a loop with its variable set to value
+func (p *Parser) expandIteration(loop *Template, name string, value string) *Check {
+	defer func() {
+		r := recover()
+		if pe, ok := r.(*ParseError); ok {
+			pe.AddNote(fmt.Sprintf("in the iteration where %s = \"%s\"", name, value), Span{})
+		}
+		if r != nil {
+			panic(r)
+		}
+	}()
+	return p.expandTemplate(loop, map[string]string{name: value}, loop.Span)
+}
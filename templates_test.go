@@ -0,0 +1,152 @@
+package aeaconf2_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/safinsingh/aeaconf2"
+)
+
+func TestTemplates(t *testing.T) {
+	source := `let etc = "/etc"
+
+template ServiceSecured(svc, conf) "${svc} is secured": 3 ["Look in ${conf}"]
+	ServiceUp "${svc}"
+	PathExists "${conf}"
+
+template Removed(user) _: _; PathExistsNot "/home/${user}"
+
+ServiceSecured("sshd", "${etc}/ssh/sshd_config")
+ServiceSecured('vsftpd', '/etc/vsftpd.conf')
+Removed("games")`
+
+	checks, err := buildChecks(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 3 {
+		t.Fatalf("expected 3 checks, got %d", len(checks))
+	}
+
+	sshd := checks[0]
+	if sshd.Message != "sshd is secured" || sshd.Points != 3 || sshd.Hint != "Look in /etc/ssh/sshd_config" {
+		t.Errorf("unexpected check: %+v", sshd)
+	}
+	and := sshd.Condition.(*aeaconf2.AndExpr)
+	if path := and.Rhs.(*PathExists).Path; path != "/etc/ssh/sshd_config" {
+		t.Errorf("unexpected argument: %q", path)
+	}
+	if sshd.Span.Start.Line != 9 {
+		t.Errorf("expected the check to be located at its instantiation, got %+v", sshd.Span)
+	}
+
+	if msg := checks[1].Message; msg != "vsftpd is secured" {
+		t.Errorf("unexpected message: %q", msg)
+	}
+	if removed := checks[2]; removed.Message != "NOT (Path '/home/games' exists)" || removed.Points != 94 {
+		t.Errorf("unexpected check: %+v", removed)
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		code    string
+		message string
+		line    int
+	}{
+		{"argument count", "template T(a, b) \"${a}\": 3; PathExists \"${b}\"\nT(\"x\")", aeaconf2.CodeArgumentCount, "template 'T' expects 2 arguments, found 1", 2},
+		{"argument type", "template T(a) \"${a}\": 3; PathExists \"/\"\nT(3)", aeaconf2.CodeArgumentType, "expected a string argument for template 'T', found number `3`", 2},
+		{"undefined parameter", "template T(a) \"${b}\": 3; PathExists \"/\"\nT(\"x\")", aeaconf2.CodeUndefinedName, "undefined name 'b' in '${b}'", 1},
+		{"keyword", "template include(x) \"${x}\": 3; PathExists \"/\"", aeaconf2.CodeSyntax, "'include' is a keyword, so can't be defined with 'template'", 1},
+		{"duplicate parameter", "template T(a, a) \"${a}\": 3; PathExists \"/\"", aeaconf2.CodeDuplicateDefinition, "template 'T' has more than one parameter named 'a'", 1},
+		{"error in unused template", "template T(a) \"${a}\": 3; PathExists 3", aeaconf2.CodeArgumentType, "expected a string for argument 'Path' of 'PathExists', found number `3`", 1},
+		{"parameters outside template", "template T(a) \"${a}\": 3; PathExists \"/\"\nT(\"x\")\n\"b\": 3; PathExists \"${a}\"", aeaconf2.CodeUndefinedName, "undefined name 'a' in '${a}'", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := buildChecks(tt.source)
+			var pe *aeaconf2.ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("expected *ParseError, got %v", err)
+			}
+			if pe.Code != tt.code || pe.Message != tt.message || pe.Line != tt.line {
+				t.Errorf("expected %s %q on line %d, got %s %q on line %d", tt.code, tt.message, tt.line, pe.Code, pe.Message, pe.Line)
+			}
+		})
+	}
+}
+
+func TestForLoops(t *testing.T) {
+	source := `let admin = "root"
+
+for user in ["bob", "eve", "${admin}2"] "Unauthorized user ${user} removed": 2
+	PathExistsNot "/home/${user}"
+
+for svc in [
+	"telnet",
+	"vsftpd",
+] _: _; ServiceUpNot "${svc}"`
+
+	checks, err := buildChecks(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 5 {
+		t.Fatalf("expected 5 checks, got %d", len(checks))
+	}
+
+	for i, user := range []string{"bob", "eve", "root2"} {
+		check := checks[i]
+		if check.Message != "Unauthorized user "+user+" removed" || check.Points != 2 {
+			t.Errorf("unexpected check %d: %+v", i, check)
+		}
+		if path := check.Condition.(*aeaconf2.NotFunc).Func.(*PathExists).Path; path != "/home/"+user {
+			t.Errorf("unexpected argument: %q", path)
+		}
+	}
+
+	// unspecified points are distributed among every generated check
+	for i, svc := range []string{"telnet", "vsftpd"} {
+		check := checks[3+i]
+		if check.Message != "NOT (Service '"+svc+"' is running)" || check.Points != 47 {
+			t.Errorf("unexpected check %d: %+v", 3+i, check)
+		}
+	}
+}
+
+func TestForLoopErrors(t *testing.T) {
+	source := "for user in [\"bob\", \"eve\"] \"User ${user} removed\": 2; PathExists 3\n\"b\": 3; ServiceUp \"sshd\""
+	_, err := buildChecks(source)
+	var errs aeaconf2.ParseErrors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("expected a single error, got %v", err)
+	}
+	pe := errs[0]
+	if pe.Code != aeaconf2.CodeArgumentType || pe.Line != 1 {
+		t.Errorf("unexpected error: %v", pe)
+	}
+	if note := pe.Notes[len(pe.Notes)-1]; note.Message != `in the iteration where user = "bob"` {
+		t.Errorf("unexpected note: %+v", note)
+	}
+
+	_, err = buildChecks(`for user "bob" "a": 3; PathExists "/"`)
+	var syntax *aeaconf2.ParseError
+	if !errors.As(err, &syntax) || syntax.Message != "expected 'in' following 'for user', found string `\"bob\"`" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// the check of an empty loop is still parsed
+	_, err = buildChecks("for user in [] \"a\": 3; Bogus \"${user}\"\n\"b\": 3; ServiceUp \"sshd\"")
+	var unknown *aeaconf2.ParseError
+	if !errors.As(err, &unknown) || unknown.Code != aeaconf2.CodeUnknownFunction {
+		t.Errorf("expected an unknown function error, got %v", err)
+	}
+
+	checks, err := buildChecks("for user in [] \"a\": 3; PathExists \"/home/${user}\"\n\"b\": 3; ServiceUp \"sshd\"")
+	if err != nil || len(checks) != 1 {
+		t.Errorf("expected only the check after the empty loop, got %v, %v", checks, err)
+	}
+}
@@ -3,6 +3,8 @@ package aeaconf2
 import (
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 )
 
 func BuildAndTree(conditions []Condition) Condition {
@@ -12,15 +14,42 @@ func BuildAndTree(conditions []Condition) Condition {
 		if result == nil {
 			result = cond
 		} else {
-			result = &AndExpr{Lhs: result, Rhs: cond}
+			and := &AndExpr{Lhs: result, Rhs: cond}
+			and.Span = ConditionSpan(result).Join(ConditionSpan(cond))
+			result = and
 		}
 	}
 
 	return result
 }
 
+func BuildOrTree(conditions []Condition) Condition {
+	var result Condition
+
+	for _, cond := range conditions {
+		if result == nil {
+			result = cond
+		} else {
+			or := &OrExpr{Lhs: result, Rhs: cond}
+			or.Span = ConditionSpan(result).Join(ConditionSpan(cond))
+			result = or
+		}
+	}
+
+	return result
+}
+
+// Keywords may not be used as function names or defined names
+var Keywords = []string{"not", "true", "false", "let", "def", "template", "for", "in", "include", "atleast", "exactly", "atmost", "any", "all", "when"}
+
 func CheckFunctionRegistry(funcs map[string]reflect.Type) {
 	for funcName, ty := range funcs {
+		for _, keyword := range Keywords {
+			if funcName == keyword {
+				panic(fmt.Sprintf("ICE: function '%s' has the name of a keyword", funcName))
+			}
+		}
+
 		if ty.NumField() == 0 {
 			panic(fmt.Sprintf("ICE: function '%s' has invalid # of arguments: 0 (must include BaseCondition)", funcName))
 		}
@@ -33,10 +62,138 @@ func CheckFunctionRegistry(funcs map[string]reflect.Type) {
 				field0.Name,
 				field0.Type))
 		}
+
+		names := make(map[string]bool)
+		for _, arg := range FunctionArguments(ty) {
+			if ArgumentTypeName(arg.Field.Type) == "" {
+				panic(fmt.Sprintf(
+					"ICE: function '%s' has argument '%s' of unsupported type '%s'",
+					funcName,
+					arg.Field.Name,
+					arg.Field.Type))
+			}
+			if names[arg.Name] {
+				panic(fmt.Sprintf("ICE: function '%s' has more than one argument named '%s'", funcName, arg.Name))
+			}
+			names[arg.Name] = true
+
+			if arg.HasDefault {
+				if _, err := parseDefault(arg); err != nil {
+					panic(fmt.Sprintf("ICE: function '%s' has invalid default for argument '%s': %s", funcName, arg.Name, err))
+				}
+			}
+		}
+
+		args := FunctionArguments(ty)
+		for i := 1; i < len(args); i++ {
+			if args[i-1].Optional && !args[i].Optional {
+				panic(fmt.Sprintf("ICE: function '%s' has required argument '%s' after optional argument '%s'",
+					funcName, args[i].Name, args[i-1].Name))
+			}
+		}
+	}
+}
+
+// FunctionSignature describes how a registered function is called, with
+// optional arguments in brackets, e.g.
+//
+//	FileOwner <Path string> [<Owner string = root>]
+func FunctionSignature(funcName string, ty reflect.Type) string {
+	parts := []string{funcName}
+	for _, arg := range FunctionArguments(ty) {
+		param := fmt.Sprintf("<%s %s>", arg.Name, ArgumentTypeName(arg.Field.Type))
+		if arg.HasDefault {
+			param = fmt.Sprintf("[<%s %s = %s>]", arg.Name, ArgumentTypeName(arg.Field.Type), arg.Default)
+		} else if arg.Optional {
+			param = "[" + param + "]"
+		}
+		parts = append(parts, param)
+	}
+	return strings.Join(parts, " ")
+}
+
+// SuggestFunctions returns the registered function names (including their
+// 'Not' variants) closest to an unknown name
+func SuggestFunctions(name string, funcs map[string]reflect.Type) []string {
+	var candidates []string
+	for funcName := range funcs {
+		candidates = append(candidates, funcName, funcName+"Not")
+	}
+	sort.Strings(candidates)
+	return suggest(name, candidates)
+}
+
+// suggest returns the candidates closest to name, which must be sorted
+func suggest(name string, candidates []string) []string {
+	lower := strings.ToLower(name)
+	for _, candidate := range candidates {
+		// differing only by case is as close as it gets
+		if strings.ToLower(candidate) == lower {
+			return []string{candidate}
+		}
+	}
+
+	// allow roughly one typo per three characters, keeping only the
+	// candidates tied for closest
+	bestDistance := max(1, len(name)/3)
+	var suggestions []string
+	for _, candidate := range candidates {
+		distance := levenshtein(lower, strings.ToLower(candidate))
+		if distance < bestDistance {
+			bestDistance = distance
+			suggestions = nil
+		}
+		if distance == bestDistance {
+			suggestions = append(suggestions, candidate)
+		}
+	}
+
+	if len(suggestions) > 3 {
+		suggestions = suggestions[:3]
+	}
+	return suggestions
+}
+
+func formatSuggestions(suggestions []string) string {
+	quoted := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		quoted[i] = "`" + s + "`"
+	}
+	if len(quoted) == 1 {
+		return quoted[0]
+	}
+	return "one of " + strings.Join(quoted, ", ")
+}
+
+func levenshtein(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
 	}
+	return "s"
 }
 
-func DistributeMaxPoints(checks []*Check, maxPoints int) {
+func DistributeMaxPoints(checks []*Check, maxPoints int) error {
 	var unspecifiedPointsChecks []*Check
 	totalCheckPoints := 0
 	for _, check := range checks {
@@ -46,22 +203,26 @@ func DistributeMaxPoints(checks []*Check, maxPoints int) {
 		}
 	}
 
+	if len(unspecifiedPointsChecks) == 0 {
+		return nil
+	}
+
 	pointsRemaining := maxPoints - totalCheckPoints
 	pointsPerCheck := pointsRemaining / len(unspecifiedPointsChecks)
 
 	if pointsPerCheck < 1 {
-		Fatal(STAGE_DISTRIBUTION,
-			fmt.Sprintf(
-				"cannot distribute points to unspecified-point vulns without overflowing maximum image points (%d). %s %d",
-				maxPoints,
-				"please adjust the configuration file: increase 'maxPoints' under '[round]' to at least",
-				totalCheckPoints+len(unspecifiedPointsChecks),
-			),
-		)
+		// point at the first check sharing the points
+		return NewParseError(STAGE_DISTRIBUTION, CodePointOverflow, nil, unspecifiedPointsChecks[0].Span, fmt.Sprintf(
+			"cannot distribute points to unspecified-point vulns without overflowing maximum image points (%d). %s %d",
+			maxPoints,
+			"please adjust the configuration file: increase 'maxPoints' under '[round]' to at least",
+			totalCheckPoints+len(unspecifiedPointsChecks),
+		))
 	}
 
 	for _, check := range unspecifiedPointsChecks {
 		check.Points = pointsPerCheck
 		check.PointsEmpty = false
 	}
+	return nil
 }